@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+)
+
+// PreviousNameAnnotation is the annotation on a workload, or on one of its resources, that holds the name it had
+// before a rename: the previous workload name, resource alias, or shared resource id.
+const PreviousNameAnnotation = "scorpion.dev/previous-name"
+
+// GeneratedComponent records a component of the last generated program so that the next generate can detect renamed
+// workloads and resources.
+type GeneratedComponent struct {
+	Name string `yaml:"name"`
+	// Type is the constructor of the component in the pkg.Func(Args) form
+	Type string `yaml:"type"`
+	// ParamsHash is a hash of the params of the component, excluding the name of a workload
+	ParamsHash string `yaml:"params_hash"`
+	// Aliases are the previous names of the component
+	Aliases []string `yaml:"aliases,omitempty"`
+	// Parent is the name of the workload component resource that parents the component, if any
+	Parent string `yaml:"parent,omitempty"`
+}
+
+// parsePreviousNameAnnotation returns the previous name from the annotations in the metadata of a workload or
+// resource, or an empty string if the annotation is not set.
+func parsePreviousNameAnnotation(metadata map[string]interface{}) (string, error) {
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	raw, ok := annotations[PreviousNameAnnotation]
+	if !ok {
+		return "", nil
+	}
+	s, ok := raw.(string)
+	if !ok || s == "" {
+		return "", fmt.Errorf("annotation %s must be a non-empty string", PreviousNameAnnotation)
+	}
+	return s, nil
+}
+
+// componentType returns the constructor of the component in the same form as ResourceComponentEntry.String.
+func componentType(n ComponentInstance) string {
+	if !n.hasConstructor() {
+		return n.constructorLabel()
+	}
+	return fmt.Sprintf("%s.%s(%s)", n.Package, n.Constructor, n.ArgsType)
+}
+
+// componentParamsHash returns a hash of the params of the component. The name in the metadata of a workload is left
+// out so that a renamed workload still matches its previous params.
+func componentParamsHash(n ComponentInstance) string {
+	params := n.Params
+	if metadata, ok := params["metadata"].(map[string]interface{}); ok && n.IsWorkload() {
+		params = maps.Clone(params)
+		metadata = maps.Clone(metadata)
+		delete(metadata, "name")
+		params["metadata"] = metadata
+	}
+	// json sorts map keys so the encoding is stable
+	raw, _ := json.Marshal(params)
+	h := sha256.Sum256(raw)
+	return hex.EncodeToString(h[:])
+}
+
+// RememberComponents returns the records of the components in the graph, sorted by name, for the next generate to
+// detect renames against. Providers are left out since their names come from the config rather than Score files.
+func (g *ComponentGraph) RememberComponents() []GeneratedComponent {
+	out := make([]GeneratedComponent, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		if n.IsProvider() {
+			continue
+		}
+		c := GeneratedComponent{
+			Name:       n.Name,
+			Type:       componentType(n),
+			ParamsHash: componentParamsHash(n),
+			Aliases:    n.Aliases,
+		}
+		if n.Workload != "" {
+			c.Parent = "workload." + n.Workload
+		}
+		out = append(out, c)
+	}
+	slices.SortFunc(out, func(a, b GeneratedComponent) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
+
+// DetectedRename is a new component that DetectRenames matched to a previous component by its type and params.
+type DetectedRename struct {
+	Name         string
+	PreviousName string
+}
+
+// DetectRenames returns the aliases to add to the components of the graph, keyed by component name, along with the
+// renames it matched, sorted by name. Components keep the aliases recorded for them, including those of the previous
+// names given by annotations. When matchParams is set, a new component without an annotation is matched to a previous
+// component that no longer exists and has the same type and params, as long as neither has any other match.
+func (g *ComponentGraph) DetectRenames(previous []GeneratedComponent, matchParams bool) (map[string][]string, []DetectedRename) {
+	previousByName := make(map[string]GeneratedComponent, len(previous))
+	for _, p := range previous {
+		previousByName[p.Name] = p
+	}
+	current := make(map[string]bool, len(g.Nodes))
+	claimed := make(map[string]bool)
+	for _, n := range g.Nodes {
+		current[n.Name] = true
+		for _, alias := range n.Aliases {
+			claimed[alias] = true
+		}
+	}
+
+	out := make(map[string][]string)
+	matches := make(map[string][]string)
+	matchCounts := make(map[string]int)
+	for _, id := range slices.Sorted(maps.Keys(g.Nodes)) {
+		n := g.Nodes[id]
+		if n.IsProvider() {
+			continue
+		} else if p, ok := previousByName[n.Name]; ok {
+			out[n.Name] = mergeUnique(out[n.Name], p.Aliases)
+			continue
+		} else if len(n.Aliases) > 0 {
+			for _, alias := range n.Aliases {
+				out[n.Name] = mergeUnique(out[n.Name], previousByName[alias].Aliases)
+			}
+			continue
+		} else if !matchParams {
+			continue
+		}
+		for _, p := range previous {
+			if !current[p.Name] && !claimed[p.Name] && p.Type == componentType(n) && p.ParamsHash == componentParamsHash(n) {
+				matches[n.Name] = append(matches[n.Name], p.Name)
+				matchCounts[p.Name]++
+			}
+		}
+	}
+	renames := make([]DetectedRename, 0)
+	for _, name := range slices.Sorted(maps.Keys(matches)) {
+		if candidates := matches[name]; len(candidates) == 1 && matchCounts[candidates[0]] == 1 {
+			p := previousByName[candidates[0]]
+			out[name] = mergeUnique([]string{p.Name}, p.Aliases)
+			renames = append(renames, DetectedRename{Name: name, PreviousName: p.Name})
+		}
+	}
+	for name, aliases := range out {
+		if len(aliases) == 0 {
+			delete(out, name)
+		}
+	}
+	return out, renames
+}
+
+// PreviousComponents returns the components recorded by the last generate of the program, or of the named stack's
+// program when stack is set. Each stack keeps its own record since its components can differ from the default ones.
+func (cfg *ScoreConfig) PreviousComponents(stack string) []GeneratedComponent {
+	if stack != "" {
+		return cfg.Stacks[stack].Generated
+	}
+	return cfg.Generated
+}
+
+// RecordComponents records the components of the graph for the next generate of the program, or of the named stack's
+// program when stack is set.
+func (cfg *ScoreConfig) RecordComponents(stack string, g ComponentGraph) {
+	if stack == "" {
+		cfg.Generated = g.RememberComponents()
+	} else if sc, ok := cfg.Stacks[stack]; ok {
+		sc.Generated = g.RememberComponents()
+		cfg.Stacks[stack] = sc
+	}
+}
+
+// AddAliases adds the aliases, keyed by component name, to the components of the graph.
+func (g *ComponentGraph) AddAliases(aliases map[string][]string) {
+	for id, n := range g.Nodes {
+		if extra, ok := aliases[n.Name]; ok {
+			n.Aliases = mergeUnique(n.Aliases, extra)
+			g.Nodes[id] = n
+		}
+	}
+}
+
+// AddUnparentedAliases records, for each workload component of the graph, the current or previous names under which
+// the last generate created it at the top level. Records written before workloads parented their components have no
+// parent, so once the next generate records the parent the aliases are no longer added.
+func (g *ComponentGraph) AddUnparentedAliases(previous []GeneratedComponent) {
+	unparented := make(map[string]bool, len(previous))
+	for _, p := range previous {
+		if p.Parent == "" {
+			unparented[p.Name] = true
+		}
+	}
+	for id, n := range g.Nodes {
+		if n.Workload == "" {
+			continue
+		}
+		n.UnparentedAliases = nil
+		for _, name := range append([]string{n.Name}, n.Aliases...) {
+			if unparented[name] {
+				n.UnparentedAliases = append(n.UnparentedAliases, name)
+			}
+		}
+		g.Nodes[id] = n
+	}
+}
+
+// buildAliasesOption returns the pulumi.Aliases option that adopts the resources of the previous names. The parent
+// of each alias defaults to the current parent whose own aliases Pulumi takes into account.
+func buildAliasesOption(aliases []string) jen.Code {
+	values := make([]jen.Code, 0, len(aliases))
+	for _, alias := range aliases {
+		values = append(values, jen.Values(jen.Dict{jen.Id("Name"): jen.Qual(DefaultPulumiPackage, "String").Call(jen.Lit(alias))}))
+	}
+	return jen.Qual(DefaultPulumiPackage, "Aliases").Call(jen.Index().Qual(DefaultPulumiPackage, "Alias").Values(values...))
+}
+
+// buildUnparentedAliasesOption returns the pulumi.Aliases option that adopts the resources that were created at the top
+// level under the current or previous names, before the component was parented.
+func buildUnparentedAliasesOption(name string, aliases []string) jen.Code {
+	values := make([]jen.Code, 0, len(aliases))
+	for _, alias := range aliases {
+		d := jen.Dict{jen.Id("NoParent"): jen.Qual(DefaultPulumiPackage, "Bool").Call(jen.True())}
+		if alias != name {
+			d[jen.Id("Name")] = jen.Qual(DefaultPulumiPackage, "String").Call(jen.Lit(alias))
+		}
+		values = append(values, jen.Values(d))
+	}
+	return jen.Qual(DefaultPulumiPackage, "Aliases").Call(jen.Index().Qual(DefaultPulumiPackage, "Alias").Values(values...))
+}
+
+// yamlAliases returns the urns of the previous names of a top-level resource of the type in the Pulumi yaml format.
+func yamlAliases(yamlType string, aliases []string) []string {
+	out := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		out = append(out, "urn:pulumi:${pulumi.stack}::${pulumi.project}::"+yamlType+"::"+alias)
+	}
+	return out
+}
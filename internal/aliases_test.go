@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerateComponentGraph_previous_name(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "annotations": map[string]interface{}{PreviousNameAnnotation: "old"}},
+				Resources: map[string]types.Resource{
+					"db":    {Type: "postgres", Metadata: map[string]interface{}{"annotations": map[string]interface{}{PreviousNameAnnotation: "database"}}},
+					"queue": {Type: "sqs"},
+					"cache": {Type: "redis", Id: ref("cache"), Metadata: map[string]interface{}{"annotations": map[string]interface{}{PreviousNameAnnotation: "redis"}}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs", YamlType: "comp:index:Workload"},
+		ResourceComponents: []ResourceComponentEntry{
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"}, ResourceType: "postgres"},
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewQueue", ArgsStruct: "QueueArgs"}, ResourceType: "sqs"},
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewCache", ArgsStruct: "CacheArgs"}, ResourceType: "redis"},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	aliases := make(map[string][]string)
+	for _, n := range g.Nodes {
+		aliases[n.Name] = n.Aliases
+	}
+	assert.Equal(t, map[string][]string{
+		"workload.foo":       {"workload.old"},
+		"workload.foo.db":    {"workload.old.database"},
+		"workload.foo.queue": {"workload.old.queue"},
+		"shared.cache":       {"shared.redis"},
+	}, aliases)
+
+	cfg.Workloads[0].Metadata["annotations"] = map[string]interface{}{PreviousNameAnnotation: 42}
+	_, err = cfg.GenerateComponentGraph()
+	assert.EqualError(t, err, "workload 'foo': annotation scorpion.dev/previous-name must be a non-empty string")
+}
+
+func TestComponentGraph_DetectRenames(t *testing.T) {
+	g := ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedCache":  {Package: "example.com/comp", Constructor: "NewCache", ArgsType: "CacheArgs", Name: "shared.cache", Params: map[string]interface{}{"size": 2}},
+			"sharedQueue":  {Package: "example.com/comp", Constructor: "NewQueue", ArgsType: "QueueArgs", Name: "shared.queue", Params: map[string]interface{}{"fifo": true}},
+			"sharedQueue2": {Package: "example.com/comp", Constructor: "NewQueue", ArgsType: "QueueArgs", Name: "shared.queue2", Params: map[string]interface{}{"fifo": true}},
+			"sharedQueue3": {Package: "example.com/comp", Constructor: "NewQueue", ArgsType: "QueueArgs", Name: "shared.queue3", Params: map[string]interface{}{"fifo": true}},
+			"sharedBucket": {Package: "example.com/comp", Constructor: "NewBucket", ArgsType: "BucketArgs", Name: "shared.bucket", Aliases: []string{"shared.files"}},
+			"workloadBar": {Package: "example.com/comp", Constructor: "NewWorkload", ArgsType: "WorkloadArgs", Name: "workload.bar", Workload: "bar", Params: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "bar"},
+			}},
+			"providerEast": {Package: "example.com/aws", Constructor: "NewProvider", ArgsType: "ProviderArgs", Name: "provider.east"},
+		},
+	}
+	previous := (&ComponentGraph{Nodes: map[ComponentGoIdentifier]ComponentInstance{
+		"sharedRedis":  {Package: "example.com/comp", Constructor: "NewCache", ArgsType: "CacheArgs", Name: "shared.redis", Params: map[string]interface{}{"size": 2}, Aliases: []string{"shared.memcache"}},
+		"sharedSqs":    {Package: "example.com/comp", Constructor: "NewQueue", ArgsType: "QueueArgs", Name: "shared.sqs", Params: map[string]interface{}{"fifo": true}},
+		"sharedFiles":  {Package: "example.com/comp", Constructor: "NewBucket", ArgsType: "BucketArgs", Name: "shared.files", Aliases: []string{"shared.blobs"}},
+		"sharedOther":  {Package: "example.com/comp", Constructor: "NewBucket", ArgsType: "BucketArgs", Name: "shared.other"},
+		"sharedQueue":  {Package: "example.com/comp", Constructor: "NewQueue", ArgsType: "QueueArgs", Name: "shared.queue", Params: map[string]interface{}{"fifo": true}, Aliases: []string{"shared.q"}},
+		"workloadFoo":  {Package: "example.com/comp", Constructor: "NewWorkload", ArgsType: "WorkloadArgs", Name: "workload.foo", Workload: "foo", Params: map[string]interface{}{"metadata": map[string]interface{}{"name": "foo"}}},
+		"providerEast": {Package: "example.com/aws", Constructor: "NewProvider", ArgsType: "ProviderArgs", Name: "provider.east"},
+	}}).RememberComponents()
+	assert.Len(t, previous, 6)
+
+	aliases, renames := g.DetectRenames(previous, false)
+	assert.Empty(t, renames)
+	assert.Equal(t, map[string][]string{
+		"shared.bucket": {"shared.blobs"},
+		"shared.queue":  {"shared.q"},
+	}, aliases, "only recorded aliases are kept without matching params")
+
+	aliases, renames = g.DetectRenames(previous, true)
+	assert.Equal(t, []DetectedRename{
+		{Name: "shared.cache", PreviousName: "shared.redis"},
+		{Name: "workload.bar", PreviousName: "workload.foo"},
+	}, renames)
+	assert.Equal(t, map[string][]string{
+		// matched by type and params
+		"shared.cache": {"shared.redis", "shared.memcache"},
+		// workloads match without their name
+		"workload.bar": {"workload.foo"},
+		// keeps the aliases recorded for its annotated previous name
+		"shared.bucket": {"shared.blobs"},
+		// keeps its own recorded aliases while shared.queue2 and shared.queue3 both match shared.sqs
+		"shared.queue": {"shared.q"},
+	}, aliases)
+
+	g.AddAliases(aliases)
+	assert.Equal(t, []string{"shared.files", "shared.blobs"}, g.Nodes["sharedBucket"].Aliases)
+	assert.Equal(t, []string{"workload.foo"}, g.Nodes["workloadBar"].Aliases)
+	assert.Empty(t, g.Nodes["sharedQueue2"].Aliases)
+}
+
+func TestScoreConfig_RecordComponents(t *testing.T) {
+	cfg := ScoreConfig{Stacks: map[string]StackConfig{"prod": {}}}
+	g := ComponentGraph{Nodes: map[ComponentGoIdentifier]ComponentInstance{
+		"sharedCache": {Package: "example.com/comp", Constructor: "NewCache", ArgsType: "CacheArgs", Name: "shared.cache"},
+	}}
+	cfg.RecordComponents("prod", g)
+	assert.Empty(t, cfg.PreviousComponents(""))
+	assert.Equal(t, g.RememberComponents(), cfg.PreviousComponents("prod"))
+
+	cfg.RecordComponents("", ComponentGraph{})
+	assert.Empty(t, cfg.PreviousComponents(""))
+	assert.Len(t, cfg.PreviousComponents("prod"), 1)
+}
+
+func TestComponentGraph_AddUnparentedAliases(t *testing.T) {
+	g := ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedCache2df2c0ec":      {Package: "example.com/comp", Constructor: "NewCache", ArgsType: "CacheArgs", Name: "shared.cache"},
+			"workloadFoo6d39e786":      {Package: "example.com/comp", Constructor: "NewWorkload", ArgsType: "WorkloadArgs", Name: "workload.foo", Workload: "foo"},
+			"workloadFooDb06fdf2c2":    {Package: "example.com/comp", Constructor: "NewDatabase", ArgsType: "DatabaseArgs", Name: "workload.foo.db", Workload: "foo", Aliases: []string{"workload.foo.database"}},
+			"workloadFooQueue67b05137": {Package: "example.com/comp", Constructor: "NewQueue", ArgsType: "QueueArgs", Name: "workload.foo.queue", Workload: "foo"},
+		},
+	}
+	g.AddUnparentedAliases([]GeneratedComponent{
+		{Name: "shared.cache"},
+		{Name: "workload.foo"},
+		{Name: "workload.foo.database"},
+		{Name: "workload.foo.queue", Parent: "workload.foo"},
+	})
+	assert.Empty(t, g.Nodes["sharedCache2df2c0ec"].UnparentedAliases)
+	assert.Equal(t, []string{"workload.foo"}, g.Nodes["workloadFoo6d39e786"].UnparentedAliases)
+	assert.Equal(t, []string{"workload.foo.database"}, g.Nodes["workloadFooDb06fdf2c2"].UnparentedAliases)
+	assert.Empty(t, g.Nodes["workloadFooQueue67b05137"].UnparentedAliases, "already created under the parent")
+
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `"workload.foo", &comp.WorkloadArgs{}, pulumi.Parent(scorpionWorkloadFooe2ad97b9), pulumi.Aliases([]pulumi.Alias{{NoParent: pulumi.Bool(true)}}))`)
+	assert.Contains(t, out, `pulumi.Parent(scorpionWorkloadFooe2ad97b9), pulumi.Aliases([]pulumi.Alias{{
+			Name:     pulumi.String("workload.foo.database"),
+			NoParent: pulumi.Bool(true),
+		}}), pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String("workload.foo.database")}}))`)
+	assert.Contains(t, out, `"workload.foo.queue", &comp.QueueArgs{}, pulumi.Parent(scorpionWorkloadFooe2ad97b9))`)
+
+	records := g.RememberComponents()
+	assert.Equal(t, "", records[0].Parent)
+	assert.Equal(t, "workload.foo", records[1].Parent)
+	g.AddUnparentedAliases(records)
+	assert.Empty(t, g.Nodes["workloadFoo6d39e786"].UnparentedAliases, "not added once the parent is recorded")
+}
+
+func TestBuildJenFile_aliases(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "annotations": map[string]interface{}{PreviousNameAnnotation: "old"}},
+				Resources: map[string]types.Resource{
+					"db":    {Type: "postgres", Metadata: map[string]interface{}{"annotations": map[string]interface{}{PreviousNameAnnotation: "database"}}},
+					"cache": {Type: "redis", Id: ref("cache"), Metadata: map[string]interface{}{"annotations": map[string]interface{}{PreviousNameAnnotation: "redis"}}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"}, ResourceType: "postgres"},
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewCache", ArgsStruct: "CacheArgs"}, ResourceType: "redis"},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `"workload.foo", scorpionWorkloadFooe2ad97b9, pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String("workload.old")}}))`)
+	assert.Contains(t, out, `pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String("workload.old.database")}}))`)
+	assert.Contains(t, out, `&comp.CacheArgs{}, pulumi.Aliases([]pulumi.Alias{{Name: pulumi.String("shared.redis")}}))`)
+}
+
+func TestBuildPulumiYaml_aliases(t *testing.T) {
+	doc, err := BuildPulumiYaml(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedCache2df2c0ec": {YamlType: "comp:index:Cache", Name: "shared.cache", Aliases: []string{"shared.redis"}},
+		},
+	}, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Contains(t, buff.String(), `    sharedCache2df2c0ec:
+        name: shared.cache
+        options:
+            aliases:
+                - urn:pulumi:${pulumi.stack}::${pulumi.project}::comp:index:Cache::shared.redis
+        type: comp:index:Cache
+`)
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseComponentOneLiner parses and validates a component in the pkg.Func(Args) one-liner form.
+func ParseComponentOneLiner(raw string) (ComponentEntry, error) {
+	e, ok := parseWorkloadProfileOneLiner(raw)
+	if !ok {
+		return e, fmt.Errorf("failed to parse '%s' as a pkg.Func(Args) component", raw)
+	} else if err := ValidateComponentEntry(e); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+// ParseFixedParams converts key=value pairs into fixed params. Values are decoded as yaml so that numbers, booleans,
+// lists, and maps keep their types.
+func ParseFixedParams(raw []string) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]interface{}, len(raw))
+	for _, r := range raw {
+		k, v, ok := strings.Cut(r, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid param '%s': expected key=value", r)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(v), &value); err != nil {
+			return nil, fmt.Errorf("invalid param '%s': %w", r, err)
+		}
+		out[k] = value
+	}
+	return out, nil
+}
+
+// ValidateResourceComponentEntry returns an error if a resource component library entry is invalid.
+func ValidateResourceComponentEntry(entry ResourceComponentEntry) error {
+	if entry.Template != nil {
+		if entry.Package != "" || entry.ConstructorFunc != "" || entry.ArgsStruct != "" {
+			return fmt.Errorf("template component cannot also have a package, constructor_func, or args_struct")
+		} else if entry.Provider != "" {
+			return fmt.Errorf("template component cannot have a provider")
+		} else if err := entry.Template.Validate(); err != nil {
+			return err
+		} else if err := validateComponentOutputsAndOptions(entry.ComponentEntry); err != nil {
+			return err
+		}
+	} else if err := ValidateComponentEntry(entry.ComponentEntry); err != nil {
+		return err
+	}
+	if entry.ResourceType == "" {
+		return fmt.Errorf("component must have a resource type")
+	} else if entry.VolumeOutput != "" && !validOutputFieldPattern.MatchString(entry.VolumeOutput) {
+		return fmt.Errorf("component contains an invalid volume output field '%s'", entry.VolumeOutput)
+	}
+	return entry.compilePatterns()
+}
+
+// AddResourceComponent validates the entry and inserts it into the library at the position, or appends it if the
+// position is negative. Since the first matching entry wins, earlier positions take precedence.
+func (cfg *ScoreConfig) AddResourceComponent(entry ResourceComponentEntry, position int) error {
+	if err := ValidateResourceComponentEntry(entry); err != nil {
+		return err
+	}
+	if position < 0 {
+		cfg.ResourceComponents = append(cfg.ResourceComponents, entry)
+	} else if position > len(cfg.ResourceComponents) {
+		return fmt.Errorf("position %d is out of range, the library has %d entries", position, len(cfg.ResourceComponents))
+	} else {
+		cfg.ResourceComponents = slices.Insert(cfg.ResourceComponents, position, entry)
+	}
+	return nil
+}
+
+// RemoveResourceComponents removes the entry at the index if the selector is an integer, otherwise it removes all
+// entries with the selector as their resource type. It returns the removed entries.
+func (cfg *ScoreConfig) RemoveResourceComponents(selector string) ([]ResourceComponentEntry, error) {
+	if i, err := strconv.Atoi(selector); err == nil {
+		if i < 0 || i >= len(cfg.ResourceComponents) {
+			return nil, fmt.Errorf("index %d is out of range, the library has %d entries", i, len(cfg.ResourceComponents))
+		}
+		removed := cfg.ResourceComponents[i]
+		cfg.ResourceComponents = slices.Delete(cfg.ResourceComponents, i, i+1)
+		return []ResourceComponentEntry{removed}, nil
+	}
+	removed := make([]ResourceComponentEntry, 0)
+	cfg.ResourceComponents = slices.DeleteFunc(cfg.ResourceComponents, func(entry ResourceComponentEntry) bool {
+		if entry.ResourceType == selector {
+			removed = append(removed, entry)
+			return true
+		}
+		return false
+	})
+	if len(removed) == 0 {
+		return nil, fmt.Errorf("no entries with resource type '%s'", selector)
+	}
+	return removed, nil
+}
+
+// WriteResourceComponentListing writes the library as an aligned table in matching order.
+func WriteResourceComponentListing(w io.Writer, library []ResourceComponentEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "INDEX\tTYPE\tCLASS REGEX\tID REGEX\tCOMPONENT\tFIXED PARAMS")
+	for i, entry := range library {
+		fixedParams := "-"
+		if len(entry.FixedParams) > 0 {
+			params := maps.Clone(entry.FixedParams)
+			for _, k := range entry.SecretFixedParams {
+				if _, ok := params[k]; ok {
+					params[k] = "[secret]"
+				}
+			}
+			raw, err := json.Marshal(params)
+			if err != nil {
+				return err
+			}
+			fixedParams = string(raw)
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i, entry.ResourceType, entry.ResourceClassRegex, entry.ResourceIdRegex, entry, fixedParams)
+	}
+	return tw.Flush()
+}
+
+// WriteResourceComponentMatches writes the result of MatchResourceComponents as an aligned table.
+func WriteResourceComponentMatches(w io.Writer, matches []ResourceComponentMatch) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "INDEX\tTYPE\tCLASS REGEX\tID REGEX\tCOMPONENT\tRESULT")
+	selected := false
+	for _, m := range matches {
+		result := "mismatched " + m.MismatchedField
+		if m.MismatchedField == "" && !selected {
+			result = "selected"
+			selected = true
+		} else if m.MismatchedField == "" {
+			result = "matched but shadowed by an earlier entry"
+		}
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", m.Index, m.Entry.ResourceType, m.Entry.ResourceClassRegex, m.Entry.ResourceIdRegex, m.Entry, result)
+	}
+	return tw.Flush()
+}
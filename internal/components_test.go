@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseComponentOneLiner(t *testing.T) {
+	e, err := ParseComponentOneLiner("github.com/example/thing.New(Args)")
+	require.NoError(t, err)
+	assert.Equal(t, ComponentEntry{Package: "github.com/example/thing", ConstructorFunc: "New", ArgsStruct: "Args"}, e)
+	assert.Equal(t, "github.com/example/thing.New(Args)", e.String())
+
+	_, err = ParseComponentOneLiner("github.com/example/thing")
+	assert.EqualError(t, err, "failed to parse 'github.com/example/thing' as a pkg.Func(Args) component")
+	_, err = ParseComponentOneLiner("github.com/example/thing.new(Args)")
+	assert.EqualError(t, err, "component contains an invalid constructor func identifier 'new'")
+}
+
+func TestParseFixedParams(t *testing.T) {
+	p, err := ParseFixedParams(nil)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	p, err = ParseFixedParams([]string{"a=hello", "b=42", "c=true", "d=[1, 2]", "e="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": "hello", "b": 42, "c": true, "d": []interface{}{1, 2}, "e": nil}, p)
+	_, err = ParseFixedParams([]string{"nope"})
+	assert.EqualError(t, err, "invalid param 'nope': expected key=value")
+}
+
+func TestValidateResourceComponentEntry(t *testing.T) {
+	entry := ResourceComponentEntry{
+		ComponentEntry:     ComponentEntry{Package: "github.com/example/thing", ConstructorFunc: "New", ArgsStruct: "Args"},
+		ResourceType:       "thing",
+		ResourceClassRegex: `.*`,
+		ResourceIdRegex:    `.*`,
+	}
+	assert.NoError(t, ValidateResourceComponentEntry(entry))
+	t.Run("missing type", func(t *testing.T) {
+		entry := entry
+		entry.ResourceType = ""
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "component must have a resource type")
+	})
+	t.Run("bad export", func(t *testing.T) {
+		entry := entry
+		entry.Exports = []string{"host", "not-a-field"}
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "component contains an invalid export field 'not-a-field'")
+	})
+	t.Run("bad secret fixed param", func(t *testing.T) {
+		entry := entry
+		entry.FixedParams = map[string]interface{}{"password": "abc"}
+		entry.SecretFixedParams = []string{"pasword"}
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "component secret fixed param field 'pasword' is not one of its fixed params")
+		entry.SecretFixedParams = []string{"not-a-field"}
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "component contains an invalid secret fixed param field 'not-a-field'")
+	})
+	t.Run("bad volume output", func(t *testing.T) {
+		entry := entry
+		entry.VolumeOutput = "not-a-field"
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "component contains an invalid volume output field 'not-a-field'")
+	})
+	t.Run("bad class regex", func(t *testing.T) {
+		entry := entry
+		entry.ResourceClassRegex = `*`
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "invalid resource_class_regex '*': error parsing regexp: missing argument to repetition operator: `*`")
+	})
+}
+
+func TestAddAndRemoveResourceComponents(t *testing.T) {
+	var cfg ScoreConfig
+	build := func(t string) ResourceComponentEntry {
+		return ResourceComponentEntry{
+			ComponentEntry:     ComponentEntry{Package: "github.com/example/" + t, ConstructorFunc: "New", ArgsStruct: "Args"},
+			ResourceType:       t,
+			ResourceClassRegex: `.*`,
+			ResourceIdRegex:    `.*`,
+		}
+	}
+	require.NoError(t, cfg.AddResourceComponent(build("a"), -1))
+	require.NoError(t, cfg.AddResourceComponent(build("b"), -1))
+	require.NoError(t, cfg.AddResourceComponent(build("c"), 0))
+	require.NoError(t, cfg.AddResourceComponent(build("a"), 3))
+	assert.EqualError(t, cfg.AddResourceComponent(build("a"), 10), "position 10 is out of range, the library has 4 entries")
+	assert.EqualError(t, cfg.AddResourceComponent(ResourceComponentEntry{ResourceType: "a"}, -1), "component contains an invalid package path ''")
+	assert.Equal(t, []ResourceComponentEntry{build("c"), build("a"), build("b"), build("a")}, cfg.ResourceComponents)
+
+	buff := new(bytes.Buffer)
+	cfg.ResourceComponents[0].FixedParams = map[string]interface{}{"x": 1, "y": "password"}
+	cfg.ResourceComponents[0].SecretFixedParams = []string{"y"}
+	require.NoError(t, WriteResourceComponentListing(buff, cfg.ResourceComponents))
+	assert.Equal(t, `INDEX  TYPE  CLASS REGEX  ID REGEX  COMPONENT                       FIXED PARAMS
+0      c     .*           .*        github.com/example/c.New(Args)  {"x":1,"y":"[secret]"}
+1      a     .*           .*        github.com/example/a.New(Args)  -
+2      b     .*           .*        github.com/example/b.New(Args)  -
+3      a     .*           .*        github.com/example/a.New(Args)  -
+`, buff.String())
+
+	removed, err := cfg.RemoveResourceComponents("a")
+	require.NoError(t, err)
+	assert.Equal(t, []ResourceComponentEntry{build("a"), build("a")}, removed)
+	removed, err = cfg.RemoveResourceComponents("1")
+	require.NoError(t, err)
+	assert.Equal(t, []ResourceComponentEntry{build("b")}, removed)
+	_, err = cfg.RemoveResourceComponents("1")
+	assert.EqualError(t, err, "index 1 is out of range, the library has 1 entries")
+	_, err = cfg.RemoveResourceComponents("a")
+	assert.EqualError(t, err, "no entries with resource type 'a'")
+	assert.Len(t, cfg.ResourceComponents, 1)
+}
@@ -2,7 +2,9 @@ package internal
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"regexp"
 	"slices"
@@ -18,6 +20,30 @@ type ScoreConfig struct {
 	Workloads                []types.Workload         `yaml:"workloads,omitempty"`
 	DefaultWorkloadComponent ComponentEntry           `yaml:"default_workload_component"`
 	ResourceComponents       []ResourceComponentEntry `yaml:"resource_components,omitempty"`
+	// OutputFormat is the default format of the generated program, either go (the default) or yaml
+	OutputFormat string `yaml:"output_format,omitempty"`
+	// Stacks are per-stack changes to the components, keyed by the Pulumi stack name. Stacks that are not listed use
+	// the components above unchanged.
+	Stacks map[string]StackConfig `yaml:"stacks,omitempty"`
+	// Providers are named Pulumi provider instances that components can use instead of the default providers. The
+	// fixed params of each entry are the provider args.
+	Providers map[string]ComponentEntry `yaml:"providers,omitempty"`
+	// Generated records the components of the last generated program. It is maintained by generate to detect renamed
+	// workloads and resources.
+	Generated []GeneratedComponent `yaml:"generated,omitempty"`
+}
+
+type StackConfig struct {
+	// WorkloadComponent replaces the default workload component in the stack
+	WorkloadComponent *ComponentEntry `yaml:"workload_component,omitempty"`
+	// ResourceComponents are matched before the default resource components in the stack
+	ResourceComponents []ResourceComponentEntry `yaml:"resource_components,omitempty"`
+	// FixedParams are merged over the fixed params of components in the stack, keyed by the component name such as
+	// workload.<name>, workload.<name>.<alias>, or shared.<id>
+	FixedParams map[string]map[string]interface{} `yaml:"fixed_params,omitempty"`
+	// Generated records the components of the last program generated for the stack with generate --stack. It is
+	// maintained by generate to detect renamed workloads and resources.
+	Generated []GeneratedComponent `yaml:"generated,omitempty"`
 }
 
 type ComponentEntry struct {
@@ -25,6 +51,23 @@ type ComponentEntry struct {
 	ConstructorFunc string                 `yaml:"constructor_func"`
 	ArgsStruct      string                 `yaml:"args_struct"`
 	FixedParams     map[string]interface{} `yaml:"fixed_params,omitempty"`
+	// YamlType is the Pulumi type token used to declare the component in the yaml output format
+	YamlType string `yaml:"yaml_type,omitempty"`
+	// Exports is an allow-list of output fields to export as stack outputs alongside the component urn
+	Exports []string `yaml:"exports,omitempty"`
+	// SecretOutputs are the output fields that hold sensitive values, references to them are wrapped as secrets
+	SecretOutputs []string `yaml:"secret_outputs,omitempty"`
+	// SecretFixedParams are the keys of the fixed params that hold sensitive values and are wrapped as secrets
+	SecretFixedParams []string `yaml:"secret_fixed_params,omitempty"`
+	// Options are the Pulumi resource options passed to the constructor
+	Options *ResourceOptions `yaml:"options,omitempty"`
+	// Provider is the name of an entry in the providers section to construct the component with
+	Provider string `yaml:"provider,omitempty"`
+}
+
+// String returns the entry in the same pkg.Func(Args) one-liner form accepted when choosing a workload profile.
+func (e ComponentEntry) String() string {
+	return fmt.Sprintf("%s.%s(%s)", e.Package, e.ConstructorFunc, e.ArgsStruct)
 }
 
 type ResourceComponentEntry struct {
@@ -32,6 +75,35 @@ type ResourceComponentEntry struct {
 	ResourceType       string `yaml:"resource_type"`
 	ResourceClassRegex string `yaml:"resource_class_regex"`
 	ResourceIdRegex    string `yaml:"resource_id_regex"`
+	// Template declares the outputs of a component generated within the program, in place of the package,
+	// constructor_func, and args_struct.
+	Template *ComponentTemplate `yaml:"template,omitempty"`
+	// VolumeOutput is the output field that is mounted when a workload uses a resource provisioned by the component as
+	// a container volume source. Only resources of components with a volume output can be mounted.
+	VolumeOutput string `yaml:"volume_output,omitempty"`
+
+	// classPattern and idPattern are compiled once by LoadConfig. Entries constructed in code compile on demand.
+	classPattern *regexp.Regexp
+	idPattern    *regexp.Regexp
+}
+
+// String returns the entry in the pkg.Func(Args) one-liner form, or template for a template component.
+func (e ResourceComponentEntry) String() string {
+	if e.Template != nil {
+		return "template"
+	}
+	return e.ComponentEntry.String()
+}
+
+// compilePatterns compiles the class and id regexes of the entry.
+func (e *ResourceComponentEntry) compilePatterns() error {
+	var err error
+	if e.classPattern, err = regexp.Compile(e.ResourceClassRegex); err != nil {
+		return fmt.Errorf("invalid resource_class_regex '%s': %w", e.ResourceClassRegex, err)
+	} else if e.idPattern, err = regexp.Compile(e.ResourceIdRegex); err != nil {
+		return fmt.Errorf("invalid resource_id_regex '%s': %w", e.ResourceIdRegex, err)
+	}
+	return nil
 }
 
 func LoadConfig() (ScoreConfig, bool, error) {
@@ -50,47 +122,86 @@ func LoadConfig() (ScoreConfig, bool, error) {
 		if err := d.Decode(&cfg); err != nil {
 			return ScoreConfig{}, false, fmt.Errorf("failed to decode config file: %w", err)
 		}
+		for i := range cfg.ResourceComponents {
+			if err := cfg.ResourceComponents[i].compilePatterns(); err != nil {
+				return ScoreConfig{}, false, fmt.Errorf("resource component %d: %w", i, err)
+			}
+		}
+		for _, stack := range slices.Sorted(maps.Keys(cfg.Stacks)) {
+			sc := cfg.Stacks[stack]
+			for i := range sc.ResourceComponents {
+				if err := sc.ResourceComponents[i].compilePatterns(); err != nil {
+					return ScoreConfig{}, false, fmt.Errorf("stack %s resource component %d: %w", stack, i, err)
+				}
+			}
+		}
 		return cfg, true, nil
 	}
 }
 
 func SaveConfig(cfg ScoreConfig) error {
-	tempName := ConfigFile + ".tmp"
-	defer func() {
-		_ = os.Remove(tempName)
-	}()
-	if f, err := os.Create(tempName); err != nil {
-		return fmt.Errorf("failed to create temporary config file: %w", err)
-	} else {
-		defer func() {
-			_ = f.Close()
-		}()
-		e := yaml.NewEncoder(f)
-		if err := e.Encode(cfg); err != nil {
+	return WriteFilesAtomically(map[string]func(io.Writer) error{ConfigFile: EncodeConfig(cfg)})
+}
+
+// EncodeConfig returns a function that writes the config as yaml, for use with WriteFilesAtomically.
+func EncodeConfig(cfg ScoreConfig) func(io.Writer) error {
+	return func(w io.Writer) error {
+		if err := yaml.NewEncoder(w).Encode(cfg); err != nil {
 			return fmt.Errorf("failed to encode config file: %w", err)
 		}
-		return os.Rename(tempName, ConfigFile)
+		return nil
+	}
+}
+
+// mismatchedField returns the yaml name of the first field of the entry that does not match the requested type,
+// class, and id, or an empty string if the entry matches.
+func (e ResourceComponentEntry) mismatchedField(resourceType, resourceClass, resourceId string) string {
+	if e.ResourceType != resourceType {
+		return "resource_type"
+	}
+	if e.classPattern == nil || e.idPattern == nil {
+		if err := e.compilePatterns(); err != nil {
+			slog.Error("failed to compile resource component patterns", slog.String("err", err.Error()))
+			if e.classPattern == nil {
+				return "resource_class_regex"
+			}
+			return "resource_id_regex"
+		}
+	}
+	if !e.classPattern.MatchString(resourceClass) {
+		return "resource_class_regex"
+	} else if !e.idPattern.MatchString(resourceId) {
+		return "resource_id_regex"
 	}
+	return ""
 }
 
 // buildResourceComponentMatcher builds a function that returns true if the entry matches the requested type, class, and id
 func buildResourceComponentMatcher(resourceType, resourceClass, resourceId string) func(entry ResourceComponentEntry) bool {
 	return func(candidate ResourceComponentEntry) bool {
-		if candidate.ResourceType != resourceType {
-			return false
-		} else if cr, err := regexp.Compile(candidate.ResourceClassRegex); err != nil {
-			slog.Error("failed to compile resource class regex", slog.String("pattern", candidate.ResourceClassRegex), slog.String("err", err.Error()))
-			return false
-		} else if !cr.MatchString(resourceClass) {
-			return false
-		} else if ir, err := regexp.Compile(candidate.ResourceIdRegex); err != nil {
-			slog.Error("failed to compile resource id regex", slog.String("pattern", candidate.ResourceIdRegex), slog.String("err", err.Error()))
-			return false
-		} else if !ir.MatchString(resourceId) {
-			return false
-		}
-		return true
+		return candidate.mismatchedField(resourceType, resourceClass, resourceId) == ""
+	}
+}
+
+type ResourceComponentMatch struct {
+	Index int
+	Entry ResourceComponentEntry
+	// MismatchedField is the yaml name of the field that failed to match, or empty if the entry matched
+	MismatchedField string
+}
+
+// MatchResourceComponents returns the result of matching every entry in the library against the requested type,
+// class, and id. Only the first matching entry is used when provisioning the resource.
+func MatchResourceComponents(library []ResourceComponentEntry, resourceType, resourceClass, resourceId string) []ResourceComponentMatch {
+	out := make([]ResourceComponentMatch, 0, len(library))
+	for i, entry := range library {
+		out = append(out, ResourceComponentMatch{
+			Index:           i,
+			Entry:           entry,
+			MismatchedField: entry.mismatchedField(resourceType, resourceClass, resourceId),
+		})
 	}
+	return out
 }
 
 // FindResourceComponent returns the FIRST entry in the library which matches the requested type, class, and id
@@ -103,6 +214,7 @@ func FindResourceComponent(library []ResourceComponentEntry, resourceType, resou
 
 var (
 	validPublicGoIdentifierPattern = regexp.MustCompile(`^[A-Z][a-zA-Z0-9_]*$`)
+	validOutputFieldPattern        = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`)
 )
 
 // ValidateComponentEntry returns an error if a component library entry is invalid.
@@ -114,5 +226,32 @@ func ValidateComponentEntry(entry ComponentEntry) error {
 	} else if !validPublicGoIdentifierPattern.MatchString(entry.ArgsStruct) {
 		return fmt.Errorf("component contains an invalid args struct identifier '%s'", entry.ArgsStruct)
 	}
+	return validateComponentOutputsAndOptions(entry)
+}
+
+// validateComponentOutputsAndOptions returns an error if the output fields or options of a component entry are invalid.
+func validateComponentOutputsAndOptions(entry ComponentEntry) error {
+	for _, field := range entry.Exports {
+		if !validOutputFieldPattern.MatchString(field) {
+			return fmt.Errorf("component contains an invalid export field '%s'", field)
+		}
+	}
+	for _, field := range entry.SecretOutputs {
+		if !validOutputFieldPattern.MatchString(field) {
+			return fmt.Errorf("component contains an invalid secret output field '%s'", field)
+		}
+	}
+	for _, field := range entry.SecretFixedParams {
+		if !validOutputFieldPattern.MatchString(field) {
+			return fmt.Errorf("component contains an invalid secret fixed param field '%s'", field)
+		} else if _, ok := entry.FixedParams[field]; !ok {
+			return fmt.Errorf("component secret fixed param field '%s' is not one of its fixed params", field)
+		}
+	}
+	if entry.Options != nil {
+		if err := entry.Options.Validate(); err != nil {
+			return fmt.Errorf("component contains invalid options: %w", err)
+		}
+	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"bytes"
 	"os"
 	"testing"
 
@@ -35,6 +36,14 @@ func TestLoadConfig_bad_content(t *testing.T) {
 	require.Equal(t, ScoreConfig{}, c)
 }
 
+func TestLoadConfig_bad_stack_pattern(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile(ConfigFile, []byte(`{"stacks":{"b":{"resource_components":[{"resource_class_regex":"*"}]},"a":{"resource_components":[{"resource_id_regex":"("}]}}}`), 0o644))
+	_, ok, err := LoadConfig()
+	require.EqualError(t, err, "stack a resource component 0: invalid resource_id_regex '(': error parsing regexp: missing closing ): `(`")
+	require.False(t, ok)
+}
+
 func TestLoadConfig_good(t *testing.T) {
 	t.Chdir(t.TempDir())
 	require.NoError(t, os.WriteFile(ConfigFile, []byte(`{"workloads":[{"apiVersion":"score.dev/v1b1","metadata":{"name":"app"},"containers":{"main":{"image":"thing"}}}]}`), 0o644))
@@ -116,3 +125,52 @@ func Test_FindResourceComponent(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, `.*`, e.ResourceClassRegex)
 }
+
+func TestLoadConfig_bad_regex(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile(ConfigFile, []byte(`{"resource_components":[{"resource_type":"a","resource_class_regex":".*","resource_id_regex":".*"},{"resource_type":"b","resource_class_regex":".*","resource_id_regex":"("}]}`), 0o644))
+	_, ok, err := LoadConfig()
+	require.EqualError(t, err, "resource component 1: invalid resource_id_regex '(': error parsing regexp: missing closing ): `(`")
+	require.False(t, ok)
+}
+
+func TestLoadConfig_compiles_patterns(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile(ConfigFile, []byte(`{"resource_components":[{"resource_type":"a","resource_class_regex":"^default$","resource_id_regex":"^shared\\."}]}`), 0o644))
+	c, ok, err := LoadConfig()
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotNil(t, c.ResourceComponents[0].classPattern)
+	require.NotNil(t, c.ResourceComponents[0].idPattern)
+	_, ok = FindResourceComponent(c.ResourceComponents, "a", "default", "shared.thing")
+	assert.True(t, ok)
+	_, ok = FindResourceComponent(c.ResourceComponents, "a", "default", "workload.foo.thing")
+	assert.False(t, ok)
+}
+
+func TestMatchResourceComponents(t *testing.T) {
+	library := []ResourceComponentEntry{
+		{ComponentEntry: ComponentEntry{Package: "example.com/a", ConstructorFunc: "New", ArgsStruct: "Args"}, ResourceType: "other", ResourceClassRegex: `.*`, ResourceIdRegex: `.*`},
+		{ComponentEntry: ComponentEntry{Package: "example.com/b", ConstructorFunc: "New", ArgsStruct: "Args"}, ResourceType: "eg", ResourceClassRegex: `^large$`, ResourceIdRegex: `.*`},
+		{ComponentEntry: ComponentEntry{Package: "example.com/c", ConstructorFunc: "New", ArgsStruct: "Args"}, ResourceType: "eg", ResourceClassRegex: `.*`, ResourceIdRegex: `^shared\.`},
+		{ComponentEntry: ComponentEntry{Package: "example.com/d", ConstructorFunc: "New", ArgsStruct: "Args"}, ResourceType: "eg", ResourceClassRegex: `.*`, ResourceIdRegex: `.*`},
+		{ComponentEntry: ComponentEntry{Package: "example.com/e", ConstructorFunc: "New", ArgsStruct: "Args"}, ResourceType: "eg", ResourceClassRegex: `.*`, ResourceIdRegex: `.*`},
+	}
+	matches := MatchResourceComponents(library, "eg", "default", "workload.foo.bar")
+	fields := make([]string, 0, len(matches))
+	for i, m := range matches {
+		assert.Equal(t, i, m.Index)
+		fields = append(fields, m.MismatchedField)
+	}
+	assert.Equal(t, []string{"resource_type", "resource_class_regex", "resource_id_regex", "", ""}, fields)
+
+	buff := new(bytes.Buffer)
+	require.NoError(t, WriteResourceComponentMatches(buff, matches))
+	assert.Equal(t, `INDEX  TYPE   CLASS REGEX  ID REGEX   COMPONENT                RESULT
+0      other  .*           .*         example.com/a.New(Args)  mismatched resource_type
+1      eg     ^large$      .*         example.com/b.New(Args)  mismatched resource_class_regex
+2      eg     .*           ^shared\.  example.com/c.New(Args)  mismatched resource_id_regex
+3      eg     .*           .*         example.com/d.New(Args)  selected
+4      eg     .*           .*         example.com/e.New(Args)  matched but shadowed by an earlier entry
+`, buff.String())
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/score-spec/score-go/framework"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentResourceType is the resource type that is provisioned by the built-in environment component when no
+// resource component matches it. Each ${resources.<alias>.<key>} reference to it becomes a lookup of the key in the
+// Pulumi stack config.
+const EnvironmentResourceType = "environment"
+
+// parseEnvironmentSecrets returns the keys listed by the secrets param of an environment resource. These keys are
+// looked up as secrets. No other params are supported.
+func parseEnvironmentSecrets(params map[string]interface{}) ([]string, error) {
+	for k := range params {
+		if k != "secrets" {
+			return nil, fmt.Errorf("environment resources only support the 'secrets' param, not '%s'", k)
+		}
+	}
+	raw, ok := params["secrets"]
+	if !ok {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("environment resource param 'secrets' must be a list of keys")
+	}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		key, ok := item.(string)
+		if !ok || !validOutputFieldPattern.MatchString(key) {
+			return nil, fmt.Errorf("environment resource param 'secrets' contains an invalid key '%v'", item)
+		}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+// walkRefs calls visit with every ${...} reference in the strings of a param value.
+func walkRefs(raw interface{}, visit func(ref string)) {
+	switch typed := raw.(type) {
+	case outputRef:
+		walkRefs(string(typed), visit)
+	case string:
+		if !strings.Contains(typed, "${") {
+			return
+		}
+		_, _ = framework.SubstituteString(typed, func(ref string) (string, error) {
+			visit(ref)
+			return "", nil
+		})
+	case []interface{}:
+		for _, v := range typed {
+			walkRefs(v, visit)
+		}
+	case map[string]interface{}:
+		for _, v := range typed {
+			walkRefs(v, visit)
+		}
+	}
+}
+
+// environmentKeys returns the keys of the environment component that the other components reference, each with the
+// sorted descriptions of the workloads, or shared resources, that reference it.
+func environmentKeys(g ComponentGraph, id ComponentGoIdentifier) (map[string][]string, error) {
+	out := make(map[string][]string)
+	fields := make(map[string]string)
+	var errs []error
+	for _, otherId := range slices.Sorted(maps.Keys(g.Dependencies)) {
+		other := g.Nodes[otherId]
+		referencedBy := "resource '" + other.Name + "'"
+		if other.Workload != "" {
+			referencedBy = "workload '" + other.Workload + "'"
+		}
+		walkRefs(other.referencingValues(), func(ref string) {
+			parts := framework.SplitRefParts(ref)
+			if len(parts) < 2 || parts[0] != "resources" || g.Dependencies[otherId][LocalAlias(parts[1])] != id {
+				return
+			} else if len(parts) != 3 || !validOutputFieldPattern.MatchString(parts[2]) {
+				errs = append(errs, fmt.Errorf("%s: invalid ref '%s': expected resources.%s.<key> with a valid key", other.Name, ref, parts[1]))
+				return
+			}
+			key := parts[2]
+			field := toParamName(key).GoString()
+			if otherKey, ok := fields[field]; ok && otherKey != key {
+				errs = append(errs, fmt.Errorf("%s: environment keys '%s' and '%s' would both become the %s field", other.Name, otherKey, key, field))
+				return
+			}
+			fields[field] = key
+			if !slices.Contains(out[key], referencedBy) {
+				out[key] = append(out[key], referencedBy)
+				slices.Sort(out[key])
+			}
+		})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// buildEnvironmentLookups returns the statements that look up the referenced keys of an environment component in the
+// stack config. A key that is not set, rather than set to an empty value, fails the program with an error that names
+// the workloads referencing it. The values are held by a struct with a field per key so that references to them work
+// like any other output.
+func buildEnvironmentLookups(g ComponentGraph, id ComponentGoIdentifier) ([]jen.Code, error) {
+	n := g.Nodes[id]
+	keys, err := environmentKeys(g, id)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	fieldDecls := make([]jen.Code, 0, len(keys))
+	lookups := make([]jen.Code, 0, len(keys))
+	for _, key := range slices.Sorted(maps.Keys(keys)) {
+		field := toParamName(key)
+		fieldDecls = append(fieldDecls, jen.Add(field).Qual(DefaultPulumiPackage, "StringOutput"))
+		lookup, value := jen.Qual(PulumiConfigPackage, "Try"), jen.Qual(DefaultPulumiPackage, "String").Call(jen.Id("v")).Dot("ToStringOutput").Call()
+		if slices.Contains(n.SecretOutputs, key) {
+			lookup, value = jen.Qual(PulumiConfigPackage, "TrySecret"), jen.Id("v")
+		}
+		lookups = append(lookups, jen.If(
+			jen.List(jen.Id("v"), jen.Err()).Op(":=").Add(lookup).Call(jen.Id("ctx"), jen.Lit(key)),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Qual("errors", "New").Call(jen.Lit(
+			fmt.Sprintf("%s references environment key '%s' which is not set in the stack config", strings.Join(keys[key], ", "), key),
+		)))).Else().Block(jen.Id(string(id)).Dot(field.GoString()).Op("=").Add(value)))
+	}
+	return append([]jen.Code{jen.Id(string(id)).Op(":=").Struct(fieldDecls...).Values()}, lookups...), nil
+}
+
+// appendYamlEnvironmentVariable declares the referenced keys of an environment component as a variable of stack config
+// interpolations and records the keys as config of the project. Pulumi yaml reports keys that are not set itself.
+func appendYamlEnvironmentVariable(g ComponentGraph, variables *yaml.Node, id ComponentGoIdentifier, configKeys map[string]bool) error {
+	keys, err := environmentKeys(g, id)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	values := make(map[string]interface{}, len(keys))
+	for key := range keys {
+		values[key] = "${" + key + "}"
+		configKeys[key] = configKeys[key] || slices.Contains(g.Nodes[id].SecretOutputs, key)
+	}
+	return appendYamlMapping(variables, string(id), values)
+}
@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseEnvironmentSecrets(t *testing.T) {
+	secrets, err := parseEnvironmentSecrets(nil)
+	require.NoError(t, err)
+	assert.Nil(t, secrets)
+	secrets, err = parseEnvironmentSecrets(map[string]interface{}{"secrets": []interface{}{"API_TOKEN", "password"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API_TOKEN", "password"}, secrets)
+	_, err = parseEnvironmentSecrets(map[string]interface{}{"prefix": "APP_"})
+	assert.EqualError(t, err, "environment resources only support the 'secrets' param, not 'prefix'")
+	_, err = parseEnvironmentSecrets(map[string]interface{}{"secrets": "API_TOKEN"})
+	assert.EqualError(t, err, "environment resource param 'secrets' must be a list of keys")
+	_, err = parseEnvironmentSecrets(map[string]interface{}{"secrets": []interface{}{"not-a-key"}})
+	assert.EqualError(t, err, "environment resource param 'secrets' contains an invalid key 'not-a-key'")
+}
+
+func TestGenerateComponentGraph_environment(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"env": {Type: "environment", Params: map[string]interface{}{"secrets": []interface{}{"API_TOKEN"}}},
+					"db":  {Type: "postgres", Params: map[string]interface{}{"region": "${resources.env.REGION}"}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"}, ResourceType: "postgres"},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	env := g.Nodes["workloadFooEnv340cc5a7"]
+	assert.True(t, env.Environment)
+	assert.Equal(t, []string{"API_TOKEN"}, env.SecretOutputs)
+	assert.Equal(t, "foo", env.Workload)
+	assert.Equal(t, map[LocalAlias]ComponentGoIdentifier{"env": "workloadFooEnv340cc5a7"}, g.Dependencies["workloadFooDb06fdf2c2"])
+
+	t.Run("bad params", func(t *testing.T) {
+		cfg.Workloads[0].Resources["env"] = types.Resource{Type: "environment", Params: map[string]interface{}{"secrets": "API_TOKEN"}}
+		_, err := cfg.GenerateComponentGraph()
+		assert.EqualError(t, err, "resource 'workload.foo.env': environment resource param 'secrets' must be a list of keys")
+	})
+
+	t.Run("resource component takes precedence", func(t *testing.T) {
+		cfg.ResourceComponents = append(cfg.ResourceComponents, ResourceComponentEntry{
+			ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewEnvironment", ArgsStruct: "EnvironmentArgs"},
+			ResourceType:   "environment",
+		})
+		g, err := cfg.GenerateComponentGraph()
+		require.NoError(t, err)
+		assert.False(t, g.Nodes["workloadFooEnv340cc5a7"].Environment)
+		assert.Equal(t, "NewEnvironment", g.Nodes["workloadFooEnv340cc5a7"].Constructor)
+	})
+}
+
+func TestBuildJenFile_environment(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Containers: map[string]types.Container{
+					"main": {Image: "nginx", Variables: map[string]string{
+						"DB_HOST":   "${resources.env.DB_HOST}",
+						"API_TOKEN": "${resources.env.API_TOKEN}",
+					}},
+				},
+				Resources: map[string]types.Resource{
+					"env": {Type: "environment", Params: map[string]interface{}{"secrets": []interface{}{"API_TOKEN"}}},
+					"db":  {Type: "postgres", Params: map[string]interface{}{"region": "${resources.env.REGION}"}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"}, ResourceType: "postgres"},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `		workloadFooEnv340cc5a7 := struct {
+			APITOKEN pulumi.StringOutput
+			DBHOST   pulumi.StringOutput
+			REGION   pulumi.StringOutput
+		}{}
+		if v, err := config.TrySecret(ctx, "API_TOKEN"); err != nil {
+			return errors.New("workload 'foo' references environment key 'API_TOKEN' which is not set in the stack config")
+		} else {
+			workloadFooEnv340cc5a7.APITOKEN = v
+		}
+		if v, err := config.Try(ctx, "DB_HOST"); err != nil {
+			return errors.New("workload 'foo' references environment key 'DB_HOST' which is not set in the stack config")
+		} else {
+			workloadFooEnv340cc5a7.DBHOST = pulumi.String(v).ToStringOutput()
+		}
+`)
+	assert.Contains(t, out, `&comp.DatabaseArgs{Region: pulumi.Sprintf("%v", workloadFooEnv340cc5a7.REGION)}`)
+	assert.Contains(t, out, `"API_TOKEN": pulumi.ToSecret(pulumi.Sprintf("%v", workloadFooEnv340cc5a7.APITOKEN)).(pulumi.StringOutput),`)
+	assert.NotContains(t, out, `ctx.Export("workload.foo.env"`)
+
+	t.Run("invalid ref", func(t *testing.T) {
+		cfg.Workloads[0].Containers["main"].Variables["ALL"] = "${resources.env}"
+		g, err := cfg.GenerateComponentGraph()
+		require.NoError(t, err)
+		_, err = BuildJenFile(g)
+		assert.EqualError(t, err, "workload.foo: invalid ref 'resources.env': expected resources.env.<key> with a valid key")
+	})
+
+	t.Run("conflicting keys", func(t *testing.T) {
+		cfg.Workloads[0].Containers["main"].Variables["ALL"] = "${resources.env.DB_HOST}"
+		cfg.Workloads[0].Resources["db"] = types.Resource{Type: "postgres", Params: map[string]interface{}{"host": "${resources.env.D_B_HOST}"}}
+		g, err := cfg.GenerateComponentGraph()
+		require.NoError(t, err)
+		_, err = BuildJenFile(g)
+		assert.EqualError(t, err, "workload.foo.db: environment keys 'DB_HOST' and 'D_B_HOST' would both become the DBHOST field")
+	})
+
+	t.Run("unreferenced", func(t *testing.T) {
+		cfg.Workloads[0].Containers = nil
+		cfg.Workloads[0].Resources["db"] = types.Resource{Type: "postgres"}
+		g, err := cfg.GenerateComponentGraph()
+		require.NoError(t, err)
+		f, err := BuildJenFile(g)
+		require.NoError(t, err)
+		out := f.GoString()
+		assert.NotContains(t, out, "workloadFooEnv340cc5a7")
+		assert.Contains(t, out, `pulumi.DependsOn([]pulumi.Resource{workloadFooDb06fdf2c2})`)
+	})
+}
+
+func TestBuildJenFile_shared_environment(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata:   map[string]interface{}{"name": "foo"},
+				Containers: map[string]types.Container{"main": {Image: "nginx", Variables: map[string]string{"DB_HOST": "${resources.env.DB_HOST}"}}},
+				Resources:  map[string]types.Resource{"env": {Type: "environment", Id: ref("env")}},
+			},
+			{
+				Metadata:   map[string]interface{}{"name": "bar"},
+				Containers: map[string]types.Container{"main": {Image: "nginx", Variables: map[string]string{"HOST": "${resources.shared-env.DB_HOST}"}}},
+				Resources:  map[string]types.Resource{"shared-env": {Type: "environment", Id: ref("env")}},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `return errors.New("workload 'bar', workload 'foo' references environment key 'DB_HOST' which is not set in the stack config")`)
+}
+
+func TestBuildPulumiYaml_environment(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Containers: map[string]types.Container{
+					"main": {Image: "nginx", Variables: map[string]string{"API_TOKEN": "${resources.env.API_TOKEN}"}},
+				},
+				Resources: map[string]types.Resource{
+					"env": {Type: "environment", Params: map[string]interface{}{"secrets": []interface{}{"API_TOKEN"}}},
+					"db":  {Type: "postgres", Params: map[string]interface{}{"region": "${resources.env.REGION}"}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs", YamlType: "comp:index:Workload"},
+		ResourceComponents: []ResourceComponentEntry{
+			{ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs", YamlType: "comp:index:Database"}, ResourceType: "postgres"},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	doc, err := BuildPulumiYaml(g, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Contains(t, buff.String(), `config:
+    API_TOKEN:
+        secret: true
+        type: string
+    REGION:
+        type: string
+variables:
+    workloadFooEnv340cc5a7:
+        API_TOKEN: ${API_TOKEN}
+        REGION: ${REGION}
+`)
+	assert.Contains(t, buff.String(), `            region: ${workloadFooEnv340cc5a7.REGION}`)
+	assert.NotContains(t, buff.String(), "workload.foo.env")
+}
+
+func TestWriteWorkloadListing_environment(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{Metadata: map[string]interface{}{"name": "foo"}, Resources: map[string]types.Resource{"env": {Type: "environment"}}},
+		},
+	}
+	buff := new(bytes.Buffer)
+	require.NoError(t, WriteWorkloadListing(buff, cfg.ListWorkloads()))
+	assert.Contains(t, buff.String(), "workloadFooEnv340cc5a7  <built-in environment>\n")
+}
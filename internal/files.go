@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+)
+
+// WriteFilesAtomically renders each file into a temporary file next to its target and only renames the temporary files
+// into place once every file has rendered successfully. If a rename fails, the files that were already replaced are
+// restored to their previous content so that the files are either all updated or none are.
+func WriteFilesAtomically(files map[string]func(io.Writer) error) error {
+	names := slices.Sorted(maps.Keys(files))
+	for _, name := range names {
+		tempName := name + ".tmp"
+		defer func() {
+			_ = os.Remove(tempName)
+		}()
+		if err := writeFile(tempName, files[name]); err != nil {
+			return err
+		}
+	}
+
+	previous := make(map[string][]byte, len(names))
+	for _, name := range names {
+		if raw, err := os.ReadFile(name); err == nil {
+			previous[name] = raw
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read existing file %s: %w", name, err)
+		}
+	}
+
+	for i, name := range names {
+		if err := os.Rename(name+".tmp", name); err != nil {
+			restoreErrs := make([]error, 0)
+			for _, restoreName := range names[:i] {
+				if raw, ok := previous[restoreName]; ok {
+					restoreErrs = append(restoreErrs, os.WriteFile(restoreName, raw, 0o644))
+				} else {
+					restoreErrs = append(restoreErrs, os.Remove(restoreName))
+				}
+			}
+			if restoreErr := errors.Join(restoreErrs...); restoreErr != nil {
+				return fmt.Errorf("failed to replace %s: %w (and failed to restore previous files: %v)", name, err, restoreErr)
+			}
+			return fmt.Errorf("failed to replace %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func writeFile(name string, render func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err := render(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeString(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+func TestWriteFilesAtomically(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("a.txt", []byte("old a"), 0o644))
+	require.NoError(t, WriteFilesAtomically(map[string]func(io.Writer) error{
+		"a.txt": writeString("new a"),
+		"b.txt": writeString("new b"),
+	}))
+	for name, content := range map[string]string{"a.txt": "new a", "b.txt": "new b"} {
+		raw, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(raw))
+	}
+	matches, _ := filepath.Glob("*.tmp")
+	assert.Empty(t, matches)
+}
+
+func TestWriteFilesAtomically_render_failure(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("a.txt", []byte("old a"), 0o644))
+	require.EqualError(t, WriteFilesAtomically(map[string]func(io.Writer) error{
+		"a.txt": writeString("new a"),
+		"b.txt": func(w io.Writer) error {
+			return fmt.Errorf("boom")
+		},
+	}), "boom")
+	raw, err := os.ReadFile("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "old a", string(raw))
+	_, err = os.Stat("b.txt")
+	assert.True(t, os.IsNotExist(err))
+	matches, _ := filepath.Glob("*.tmp")
+	assert.Empty(t, matches)
+}
+
+func TestWriteFilesAtomically_target_is_directory(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.WriteFile("a.txt", []byte("old a"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join("b", "child"), 0o755))
+	err := WriteFilesAtomically(map[string]func(io.Writer) error{
+		"a.txt": writeString("new a"),
+		"b":     writeString("new b"),
+	})
+	require.EqualError(t, err, "failed to read existing file b: read b: is a directory")
+	raw, err := os.ReadFile("a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "old a", string(raw))
+}
@@ -2,10 +2,14 @@ package internal
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	gotypes "go/types"
 	"hash/fnv"
+	"io"
 	"maps"
 	"reflect"
 	"slices"
@@ -14,10 +18,15 @@ import (
 
 	"github.com/dave/jennifer/jen"
 	"github.com/score-spec/score-go/framework"
+	"github.com/score-spec/score-go/types"
+	"gopkg.in/yaml.v3"
 )
 
 const (
 	DefaultPulumiPackage = "github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+	PulumiConfigPackage  = "github.com/pulumi/pulumi/sdk/v3/go/pulumi/config"
+	// WorkloadComponentType is the type of the component resource that parents a workload and its private resources
+	WorkloadComponentType = "scorpion:workload"
 )
 
 type ComponentInstance struct {
@@ -28,6 +37,8 @@ type ComponentInstance struct {
 	Constructor string
 	// ArgsType is the type of the arguments to the constructor, such as InstanceArgs
 	ArgsType string
+	// YamlType is the Pulumi type token used by the yaml output format, such as aws:rds:Instance
+	YamlType string
 
 	// Name is the name of the pulumi resource
 	Name string
@@ -39,6 +50,40 @@ type ComponentInstance struct {
 	// FixedParams are those defined in the configuration, take precedence over Params and are not subject to
 	// substitution.
 	FixedParams map[string]interface{}
+	// Exports are the output fields exported as a stack output named after the component, in addition to the urn
+	Exports []string
+	// SecretOutputs are the output fields that are wrapped as secrets wherever they are referenced or exported
+	SecretOutputs []string
+	// SecretFixedParams are the keys of the FixedParams that are wrapped as secrets
+	SecretFixedParams []string
+	// Options are the resource options from the component entry and the annotations of the workloads
+	Options ResourceOptions
+	// Provider is the provider component passed to the constructor instead of the default provider
+	Provider ComponentGoIdentifier
+	// Workload is the name of the workload that owns the component. Workloads and their private resources are
+	// constructed as children of a component resource for the workload while shared resources and providers have none.
+	Workload string
+	// Aliases are the previous names of the component, so that Pulumi adopts the existing resources after a rename
+	Aliases []string
+	// UnparentedAliases are the current or previous names under which the last generate created the workload component
+	// at the top level, before workloads parented their components
+	UnparentedAliases []string
+	// Template declares the outputs of a component that is generated within the program instead of being constructed
+	// from the package, in which case TemplateOutputs are the outputs rendered for this resource.
+	Template        *ComponentTemplate
+	TemplateOutputs map[string]interface{}
+	// Environment is true for the built-in component of the environment resource type whose outputs are looked up in
+	// the stack config. Its SecretOutputs are the keys listed by the secrets param.
+	Environment bool
+	// VolumeOutput is the output field that is mounted when a workload uses the resource as a container volume source.
+	VolumeOutput string
+
+	// argsTypeInfo is the type of the args struct when loaded by LoadArgsTypes, it is used to generate values that
+	// match the field types exactly.
+	argsTypeInfo *gotypes.Named
+	// outputsTypeInfo is the type of the outputs struct returned by the constructor when loaded by LoadArgsTypes, it
+	// is used to check that outputs passed through to other components match the field types.
+	outputsTypeInfo *gotypes.Named
 }
 
 type ComponentGoIdentifier string
@@ -63,6 +108,11 @@ func (g *ComponentGraph) VisitInDependencyOrder(visit func(ComponentGoIdentifier
 			return nil
 		}
 		visiting[node] = true
+		if p := g.Nodes[node].Provider; p != "" {
+			if err := visitNode(p); err != nil {
+				return err
+			}
+		}
 		for _, alias := range slices.Sorted(maps.Keys(g.Dependencies[node])) {
 			if err := visitNode(g.Dependencies[node][alias]); err != nil {
 				return err
@@ -124,6 +174,16 @@ func structToGeneric(s interface{}) (map[string]interface{}, error) {
 	return out, nil
 }
 
+// parsePulumiConfigRef parses a ${pulumi.config.<key>} or ${pulumi.secret.<key>} reference to stack config and
+// returns the config key and whether it is a secret.
+func parsePulumiConfigRef(ref string) (string, bool, error) {
+	parts := framework.SplitRefParts(ref)
+	if len(parts) != 3 || (parts[1] != "config" && parts[1] != "secret") || parts[2] == "" {
+		return "", false, fmt.Errorf("invalid ref '%s': expected pulumi.config.<key> or pulumi.secret.<key>", ref)
+	}
+	return parts[2], parts[1] == "secret", nil
+}
+
 func buildSubstitutionTracker(metadata map[string]interface{}, hook func(alias string) error) framework.Substituter {
 	inner := framework.BuildSubstitutionFunction(metadata, nil)
 	return framework.Substituter{
@@ -135,6 +195,11 @@ func buildSubstitutionTracker(metadata map[string]interface{}, hook func(alias s
 					return "", err
 				}
 				return "${" + s + "}", nil
+			} else if parts[0] == "pulumi" {
+				if _, _, err := parsePulumiConfigRef(s); err != nil {
+					return "", err
+				}
+				return "${" + s + "}", nil
 			}
 			return inner(s)
 		},
@@ -145,42 +210,205 @@ func buildSubstitutionTracker(metadata map[string]interface{}, hook func(alias s
 	}
 }
 
+// resolveResourceIdAndClass returns the resource id (either shared.<id> or workload.<name>.<alias>) and the class
+// of a resource declared by a workload.
+func resolveResourceIdAndClass(workloadName, alias string, res types.Resource) (string, string) {
+	resId := "workload." + workloadName + "." + alias
+	if res.Id != nil {
+		resId = "shared." + *res.Id
+	}
+	resClass := "default"
+	if res.Class != nil {
+		resClass = *res.Class
+	}
+	return resId, resClass
+}
+
+// outputRef is a param value holding a single ${resources.<alias>.<field>} placeholder whose output is passed through
+// by the generators as it is, rather than formatted into a string.
+type outputRef string
+
+// substituteContainers returns a copy of the workload containers where metadata placeholders in file content are
+// replaced and resource placeholders are validated and kept for the generator to turn into outputs. Content marked
+// with noExpand is escaped so that it is passed through verbatim. Volume sources must reference a resource whose
+// component has a volume output, given by alias in volumeOutputs, and a bare ${resources.<alias>} reference is resolved
+// to that output.
+func substituteContainers(workloadName string, workload types.Workload, volumeOutputs map[string]string) (map[string]types.Container, error) {
+	if workload.Containers == nil {
+		return nil, nil
+	}
+	out := make(map[string]types.Container, len(workload.Containers))
+	for containerName, container := range workload.Containers {
+		if container.Files != nil {
+			files := make(types.ContainerFiles, len(container.Files))
+			for target, file := range container.Files {
+				if file.Content != nil && strings.Contains(*file.Content, "${") {
+					var content string
+					if file.NoExpand != nil && *file.NoExpand {
+						content = strings.ReplaceAll(*file.Content, "$", "$$")
+					} else {
+						tracker := buildSubstitutionTracker(workload.Metadata, func(alias string) error {
+							if _, ok := workload.Resources[alias]; !ok {
+								return fmt.Errorf("unknown resource alias %q referenced by file %q in container %q of workload %q", alias, target, containerName, workloadName)
+							}
+							return nil
+						})
+						var err error
+						if content, err = tracker.SubstituteString(*file.Content); err != nil {
+							return nil, fmt.Errorf("containers.%s.files.%s: %w", containerName, target, err)
+						}
+					}
+					file.Content = &content
+				}
+				files[target] = file
+			}
+			container.Files = files
+		}
+		if container.Volumes != nil {
+			volumes := make(types.ContainerVolumes, len(container.Volumes))
+			for target, volume := range container.Volumes {
+				source, err := resolveVolumeSource(workload, volume.Source, volumeOutputs)
+				if err != nil {
+					return nil, fmt.Errorf("containers.%s.volumes.%s: %w", containerName, target, err)
+				}
+				volume.Source = source
+				volumes[target] = volume
+			}
+			container.Volumes = volumes
+		}
+		out[containerName] = container
+	}
+	return out, nil
+}
+
+// resolveVolumeSource validates that a volume source is a single placeholder referencing a resource of the workload
+// that has a volume output and returns the placeholder for the output of the resource that should be mounted.
+func resolveVolumeSource(workload types.Workload, source string, volumeOutputs map[string]string) (string, error) {
+	inner, ok := strings.CutPrefix(source, "${")
+	if ok {
+		inner, ok = strings.CutSuffix(inner, "}")
+	}
+	if !ok || strings.ContainsAny(inner, "${}") {
+		return "", fmt.Errorf("volume source %q must be a single ${resources.<alias>} reference", source)
+	}
+	parts := framework.SplitRefParts(inner)
+	if len(parts) < 2 || parts[0] != "resources" {
+		return "", fmt.Errorf("volume source %q must be a single ${resources.<alias>} reference", source)
+	}
+	res, ok := workload.Resources[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("volume source %q references an unknown resource %q", source, parts[1])
+	}
+	volumeOutput, ok := volumeOutputs[parts[1]]
+	if !ok {
+		return "", fmt.Errorf("volume source %q references resource %q of type %q whose component has no volume_output configured", source, parts[1], res.Type)
+	}
+	if len(parts) == 2 {
+		return "${" + inner + "." + volumeOutput + "}", nil
+	}
+	return source, nil
+}
+
+// markVolumeSources replaces the sources of the container volumes in the generic form of the containers with
+// outputRefs so that the generators mount the referenced output as it is.
+func markVolumeSources(containers map[string]interface{}) {
+	for _, container := range containers {
+		c, _ := container.(map[string]interface{})
+		volumes, _ := c["volumes"].(map[string]interface{})
+		for _, volume := range volumes {
+			if v, ok := volume.(map[string]interface{}); ok {
+				if source, ok := v["source"].(string); ok {
+					v["source"] = outputRef(source)
+				}
+			}
+		}
+	}
+}
+
 func (cfg *ScoreConfig) GenerateComponentGraph() (ComponentGraph, error) {
 	g := ComponentGraph{
 		Nodes:        make(map[ComponentGoIdentifier]ComponentInstance),
 		Dependencies: make(map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier),
 	}
 
+	if err := ValidateComponentEntry(cfg.DefaultWorkloadComponent); err != nil {
+		return g, fmt.Errorf("config contains an invalid default workload component spec: %v", err)
+	}
+	for i, entry := range cfg.ResourceComponents {
+		if err := ValidateResourceComponentEntry(entry); err != nil {
+			return g, fmt.Errorf("config contains an invalid resource component spec (%d): %v", i, err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Providers)) {
+		if err := ValidateComponentEntry(cfg.Providers[name]); err != nil {
+			return g, fmt.Errorf("config contains an invalid provider spec '%s': %v", name, err)
+		} else if cfg.Providers[name].Provider != "" {
+			return g, fmt.Errorf("config contains an invalid provider spec '%s': providers cannot reference another provider", name)
+		}
+	}
+
 	for _, workload := range cfg.Workloads {
 		workloadName := workload.Metadata["name"].(string)
 		workloadGoIdentifier := GenerateGoVar("workload." + workloadName)
 		workloadDeps := make(map[LocalAlias]ComponentGoIdentifier)
+		previousWorkloadName, err := parsePreviousNameAnnotation(workload.Metadata)
+		if err != nil {
+			return g, fmt.Errorf("workload '%s': %w", workloadName, err)
+		}
 
 		for alias, res := range workload.Resources {
-			resId := "workload." + workloadName + "." + alias
-			if res.Id != nil {
-				resId = "shared." + *res.Id
-			}
-			resClass := "default"
-			if res.Class != nil {
-				resClass = *res.Class
-			}
+			resId, resClass := resolveResourceIdAndClass(workloadName, alias, res)
 			resGoIdentifier := GenerateGoVar(resId)
 			c, ok := g.Nodes[resGoIdentifier]
 			if !ok {
 				componentEntry, ok := FindResourceComponent(cfg.ResourceComponents, res.Type, resClass, resId)
-				if !ok {
+				// resource components take precedence over the built-in environment component
+				isEnvironment := !ok && res.Type == EnvironmentResourceType
+				if !ok && !isEnvironment {
 					return g, fmt.Errorf("failed to find an entry in the component library to provision resource '%s' with type '%s' and class '%s'", resId, res.Type, resClass)
 				}
-				// TODO: we need to validate the resource component entries
 				c = ComponentInstance{
-					Package:     componentEntry.Package,
-					Constructor: componentEntry.ConstructorFunc,
-					ArgsType:    componentEntry.ArgsStruct,
-					FixedParams: componentEntry.FixedParams,
-					Name:        resId,
+					Package:           componentEntry.Package,
+					Constructor:       componentEntry.ConstructorFunc,
+					ArgsType:          componentEntry.ArgsStruct,
+					YamlType:          componentEntry.YamlType,
+					FixedParams:       componentEntry.FixedParams,
+					Exports:           componentEntry.Exports,
+					SecretOutputs:     componentEntry.SecretOutputs,
+					SecretFixedParams: componentEntry.SecretFixedParams,
+					Name:              resId,
+					Template:          componentEntry.Template,
+					Environment:       isEnvironment,
+					VolumeOutput:      componentEntry.VolumeOutput,
+				}
+				if !c.IsShared() {
+					c.Workload = workloadName
+				}
+				if componentEntry.Options != nil {
+					c.Options = *componentEntry.Options
+				}
+				if componentEntry.Provider != "" {
+					provider, err := cfg.addProviderNode(g, componentEntry.Provider)
+					if err != nil {
+						return g, fmt.Errorf("resource '%s': %w", resId, err)
+					}
+					c.Provider = provider
 				}
 			}
+			if o, err := parseOptionsAnnotation(res.Metadata); err != nil {
+				return g, fmt.Errorf("resource '%s' of workload '%s': %w", alias, workloadName, err)
+			} else {
+				c.Options = c.Options.Merge(o)
+			}
+			if previousName, err := parsePreviousNameAnnotation(res.Metadata); err != nil {
+				return g, fmt.Errorf("resource '%s' of workload '%s': %w", alias, workloadName, err)
+			} else if res.Id != nil && previousName != "" {
+				c.Aliases = mergeUnique(c.Aliases, []string{"shared." + previousName})
+			} else if res.Id == nil && (previousName != "" || previousWorkloadName != "") {
+				// private resources are renamed along with their workload
+				previousId := "workload." + cmp.Or(previousWorkloadName, workloadName) + "." + cmp.Or(previousName, alias)
+				c.Aliases = mergeUnique(c.Aliases, []string{previousId})
+			}
 			if res.Params != nil {
 				if c.Params != nil && !reflect.DeepEqual(res.Params, c.Params) {
 					return g, fmt.Errorf("duplicate resource %q with conflicting parameters", resId)
@@ -194,10 +422,9 @@ func (cfg *ScoreConfig) GenerateComponentGraph() (ComponentGraph, error) {
 			tracker := buildSubstitutionTracker(workload.Metadata, func(otherAlias string) error {
 				if r, ok := workload.Resources[otherAlias]; !ok {
 					return fmt.Errorf("unknown resource alias %q referenced by params in %q", otherAlias, resId)
-				} else if r.Id != nil {
-					resDeps[LocalAlias(otherAlias)] = GenerateGoVar("shared." + *r.Id)
 				} else {
-					resDeps[LocalAlias(otherAlias)] = GenerateGoVar("workload." + workloadName + "." + otherAlias)
+					otherId, _ := resolveResourceIdAndClass(workloadName, otherAlias, r)
+					resDeps[LocalAlias(otherAlias)] = GenerateGoVar(otherId)
 				}
 				return nil
 			})
@@ -206,6 +433,30 @@ func (cfg *ScoreConfig) GenerateComponentGraph() (ComponentGraph, error) {
 			} else {
 				c.Params = cp.(map[string]interface{})
 			}
+			if c.Environment {
+				if c.SecretOutputs, err = parseEnvironmentSecrets(c.Params); err != nil {
+					return g, fmt.Errorf("resource '%s': %w", resId, err)
+				}
+			} else if c.Template != nil {
+				// outputs are rendered from the params so they are rendered again by the workload that defines them
+				if c.TemplateOutputs == nil || c.ParamsDefinedBy == workloadGoIdentifier {
+					params := maps.Clone(c.Params)
+					if params == nil {
+						params = make(map[string]interface{}, len(c.FixedParams))
+					}
+					maps.Copy(params, c.FixedParams)
+					if c.TemplateOutputs, err = c.Template.render(templateData{
+						Id: resId, Type: res.Type, Class: resClass, Params: params, Metadata: res.Metadata, WorkloadMetadata: workload.Metadata,
+					}); err != nil {
+						return g, fmt.Errorf("resource '%s': %w", resId, err)
+					}
+				}
+				if co, err := tracker.Substitute(c.TemplateOutputs); err != nil {
+					return g, err
+				} else {
+					c.TemplateOutputs = co.(map[string]interface{})
+				}
+			}
 
 			g.Nodes[resGoIdentifier] = c
 			if len(resDeps) > 0 {
@@ -220,9 +471,18 @@ func (cfg *ScoreConfig) GenerateComponentGraph() (ComponentGraph, error) {
 		} else {
 			workloadParams["metadata"] = m
 		}
-		if c, err := structToGeneric(workload.Containers); err != nil {
+		volumeOutputs := make(map[string]string)
+		for alias, id := range workloadDeps {
+			if o := g.Nodes[id].VolumeOutput; o != "" {
+				volumeOutputs[string(alias)] = o
+			}
+		}
+		if containers, err := substituteContainers(workloadName, workload, volumeOutputs); err != nil {
+			return g, err
+		} else if c, err := structToGeneric(containers); err != nil {
 			return g, err
 		} else {
+			markVolumeSources(c)
 			workloadParams["containers"] = c
 		}
 		if workload.Service != nil {
@@ -233,15 +493,39 @@ func (cfg *ScoreConfig) GenerateComponentGraph() (ComponentGraph, error) {
 			}
 		}
 
-		// TODO: we need to validate the workload component entry
+		workloadOptions, err := parseOptionsAnnotation(workload.Metadata)
+		if err != nil {
+			return g, fmt.Errorf("workload '%s': %w", workloadName, err)
+		} else if cfg.DefaultWorkloadComponent.Options != nil {
+			workloadOptions = cfg.DefaultWorkloadComponent.Options.Merge(workloadOptions)
+		}
+		var workloadAliases []string
+		if previousWorkloadName != "" {
+			workloadAliases = []string{"workload." + previousWorkloadName}
+		}
+		var workloadProvider ComponentGoIdentifier
+		if cfg.DefaultWorkloadComponent.Provider != "" {
+			if workloadProvider, err = cfg.addProviderNode(g, cfg.DefaultWorkloadComponent.Provider); err != nil {
+				return g, fmt.Errorf("workload '%s': %w", workloadName, err)
+			}
+		}
+
 		g.Nodes[workloadGoIdentifier] = ComponentInstance{
-			Package:         cfg.DefaultWorkloadComponent.Package,
-			Constructor:     cfg.DefaultWorkloadComponent.ConstructorFunc,
-			ArgsType:        cfg.DefaultWorkloadComponent.ArgsStruct,
-			FixedParams:     cfg.DefaultWorkloadComponent.FixedParams,
-			Name:            "workload." + workloadName,
-			Params:          workloadParams,
-			ParamsDefinedBy: workloadGoIdentifier,
+			Package:           cfg.DefaultWorkloadComponent.Package,
+			Constructor:       cfg.DefaultWorkloadComponent.ConstructorFunc,
+			ArgsType:          cfg.DefaultWorkloadComponent.ArgsStruct,
+			YamlType:          cfg.DefaultWorkloadComponent.YamlType,
+			FixedParams:       cfg.DefaultWorkloadComponent.FixedParams,
+			Exports:           cfg.DefaultWorkloadComponent.Exports,
+			SecretOutputs:     cfg.DefaultWorkloadComponent.SecretOutputs,
+			SecretFixedParams: cfg.DefaultWorkloadComponent.SecretFixedParams,
+			Options:           workloadOptions,
+			Provider:          workloadProvider,
+			Name:              "workload." + workloadName,
+			Workload:          workloadName,
+			Aliases:           workloadAliases,
+			Params:            workloadParams,
+			ParamsDefinedBy:   workloadGoIdentifier,
 		}
 		if len(workloadDeps) > 0 {
 			g.Dependencies[workloadGoIdentifier] = workloadDeps
@@ -251,6 +535,89 @@ func (cfg *ScoreConfig) GenerateComponentGraph() (ComponentGraph, error) {
 	return g, nil
 }
 
+// addProviderNode adds the named provider from the providers section to the graph if it is not already present and
+// returns its identifier.
+func (cfg *ScoreConfig) addProviderNode(g ComponentGraph, name string) (ComponentGoIdentifier, error) {
+	entry, ok := cfg.Providers[name]
+	if !ok {
+		return "", fmt.Errorf("component references unknown provider '%s'", name)
+	}
+	id := GenerateGoVar("provider." + name)
+	if _, ok := g.Nodes[id]; !ok {
+		c := ComponentInstance{
+			Package:           entry.Package,
+			Constructor:       entry.ConstructorFunc,
+			ArgsType:          entry.ArgsStruct,
+			YamlType:          entry.YamlType,
+			FixedParams:       entry.FixedParams,
+			SecretFixedParams: entry.SecretFixedParams,
+			Name:              "provider." + name,
+		}
+		if entry.Options != nil {
+			c.Options = *entry.Options
+		}
+		g.Nodes[id] = c
+	}
+	return id, nil
+}
+
+// ForStack returns the config with the changes of the named stack applied to the components. The stack must be
+// listed in the stacks section.
+func (cfg *ScoreConfig) ForStack(stack string) (ScoreConfig, error) {
+	sc, ok := cfg.Stacks[stack]
+	if !ok {
+		return ScoreConfig{}, fmt.Errorf("stack '%s' is not defined in the stacks section of the config", stack)
+	}
+	out := *cfg
+	out.Stacks = nil
+	if sc.WorkloadComponent != nil {
+		out.DefaultWorkloadComponent = *sc.WorkloadComponent
+	}
+	out.ResourceComponents = slices.Concat(sc.ResourceComponents, cfg.ResourceComponents)
+	return out, nil
+}
+
+// GenerateStackComponentGraph generates the component graph for the named stack. The fixed params of the stack are
+// merged over those of the matching components and must each refer to a component in the graph.
+func (cfg *ScoreConfig) GenerateStackComponentGraph(stack string) (ComponentGraph, error) {
+	stackCfg, err := cfg.ForStack(stack)
+	if err != nil {
+		return ComponentGraph{}, err
+	}
+	g, err := stackCfg.GenerateComponentGraph()
+	if err != nil {
+		return g, fmt.Errorf("stack %s: %w", stack, err)
+	}
+	fixedParams := cfg.Stacks[stack].FixedParams
+	for _, name := range slices.Sorted(maps.Keys(fixedParams)) {
+		id := GenerateGoVar(name)
+		n, ok := g.Nodes[id]
+		if !ok || n.Name != name {
+			return g, fmt.Errorf("stack %s: fixed params refer to unknown component '%s'", stack, name)
+		}
+		merged := make(map[string]interface{}, len(n.FixedParams)+len(fixedParams[name]))
+		maps.Copy(merged, n.FixedParams)
+		maps.Copy(merged, fixedParams[name])
+		n.FixedParams = merged
+		g.Nodes[id] = n
+	}
+	return g, nil
+}
+
+// GenerateStackComponentGraphs generates the component graph of every stack in the stacks section, keyed by the
+// stack name.
+func (cfg *ScoreConfig) GenerateStackComponentGraphs() (map[string]ComponentGraph, error) {
+	out := make(map[string]ComponentGraph, len(cfg.Stacks))
+	for _, stack := range slices.Sorted(maps.Keys(cfg.Stacks)) {
+		g, err := cfg.GenerateStackComponentGraph(stack)
+		if err != nil {
+			return nil, err
+		}
+		out[stack] = g
+	}
+	return out, nil
+}
+
 func mapLookupOutput(ctx map[string]interface{}) func(keys ...string) (interface{}, error) {
 	return func(keys ...string) (interface{}, error) {
 		var resolvedValue interface{}
@@ -299,6 +666,17 @@ func buildInnerSubstitutionFunc(metadata map[string]interface{}, dependencies ma
 				}
 				*fmtArgs = append(*fmtArgs, c)
 				return "%v", nil
+			case "pulumi":
+				key, secret, err := parsePulumiConfigRef(ref)
+				if err != nil {
+					return "", err
+				}
+				if secret {
+					*fmtArgs = append(*fmtArgs, jen.Qual(PulumiConfigPackage, "RequireSecret").Call(jen.Id("ctx"), jen.Lit(key)))
+				} else {
+					*fmtArgs = append(*fmtArgs, jen.Qual(PulumiConfigPackage, "Require").Call(jen.Id("ctx"), jen.Lit(key)))
+				}
+				return "%v", nil
 			default:
 				return "", fmt.Errorf("invalid ref '%s': unknown reference root, use $$ to escape the substitution", ref)
 			}
@@ -311,6 +689,18 @@ func pulumifyValue(path []string, raw interface{}, innerSubstFunc func(fmtArgs *
 		return jen.Nil(), nil
 	}
 	switch typed := raw.(type) {
+	case secretValue:
+		inner, err := pulumifyValue(path, typed.value, innerSubstFunc)
+		if err != nil || typed.value == nil {
+			return inner, err
+		}
+		return wrapSecret(inner, untypedSecretOutputType(typed.value)), nil
+	case outputRef:
+		fmtArgs := make([]jen.Code, 0, 1)
+		if _, err := framework.SubstituteString(string(typed), innerSubstFunc(&fmtArgs)); err != nil {
+			return nil, fmt.Errorf("failed to substitute %q at %s: %w", typed, strings.Join(path, "."), err)
+		}
+		return fmtArgs[0], nil
 	case string:
 		if strings.Contains(typed, "${") {
 			typed = strings.ReplaceAll(typed, "%", "%%")
@@ -379,42 +769,184 @@ func toParamName(p string) *jen.Statement {
 	return jen.Id(buff.String())
 }
 
-func BuildJenFile(g ComponentGraph) (*jen.File, error) {
-	f := jen.NewFile("main")
+// buildExports returns the stack output map for a component: its urn and each allow-listed output field. Secret
+// output fields are exported as secrets.
+func buildExports(id ComponentGoIdentifier, fields []string, secretFields []string) jen.Dict {
+	out := jen.Dict{jen.Lit("urn"): jen.Id(string(id)).Dot("URN").Call()}
+	for _, field := range fields {
+		var c jen.Code = jen.Id(string(id)).Dot(toParamName(field).GoString())
+		if slices.Contains(secretFields, field) {
+			c = wrapSecret(c, nil)
+		}
+		out[jen.Lit(field)] = c
+	}
+	return out
+}
 
+// unreferencedDependencies returns the dependencies of a component, sorted and without duplicates, that are not
+// referenced by its params with at least minRefParts ref parts. Such references already make Pulumi wait for the
+// dependency, so only the remaining dependencies need to be declared explicitly.
+func unreferencedDependencies(params map[string]interface{}, dependencies map[LocalAlias]ComponentGoIdentifier, minRefParts int) []ComponentGoIdentifier {
+	referenced := make(map[ComponentGoIdentifier]bool)
+	walkRefs(params, func(ref string) {
+		if parts := framework.SplitRefParts(ref); len(parts) >= minRefParts && parts[0] == "resources" {
+			if dep, ok := dependencies[LocalAlias(parts[1])]; ok {
+				referenced[dep] = true
+			}
+		}
+	})
+	out := make([]ComponentGoIdentifier, 0)
+	for _, dep := range dependencies {
+		if !referenced[dep] && !slices.Contains(out, dep) {
+			out = append(out, dep)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// referencingValues returns the values of the component that may reference its dependencies: the rendered outputs of
+// a template component or the params of any other component.
+func (c ComponentInstance) referencingValues() map[string]interface{} {
+	if c.Template != nil {
+		return c.TemplateOutputs
+	}
+	return c.Params
+}
+
+// workloadParentGoVar returns the identifier of the component resource that parents the components of a workload.
+func workloadParentGoVar(workloadName string) ComponentGoIdentifier {
+	return GenerateGoVar(WorkloadComponentType + "." + workloadName)
+}
+
+// buildProgramBlock returns the statements that construct the components of the graph within a function that has
+// a ctx variable and returns an error. The component resource of each workload is registered before the first of
+// its components and its outputs are registered once the workload component itself has been constructed.
+func buildProgramBlock(g ComponentGraph, templateTypes map[string]jen.Code) ([]jen.Code, error) {
 	blockParts := make([]jen.Code, 0)
+	typeErrs := make([]error, 0)
+	registeredParents := make(map[string]bool)
 	if err := g.VisitInDependencyOrder(func(id ComponentGoIdentifier) error {
 		n := g.Nodes[id]
+		// the environment is a set of stack config lookups rather than a resource
+		if n.Environment {
+			statements, err := buildEnvironmentLookups(g, id)
+			if err != nil {
+				return err
+			}
+			blockParts = append(blockParts, statements...)
+			return nil
+		}
 
-		substFunc := buildInnerSubstitutionFunc(n.Params, g.Dependencies[id])
-		argAssignments := make(jen.Dict, len(n.Params)+len(n.FixedParams))
-		for _, m := range []map[string]interface{}{n.Params, n.FixedParams} {
-			for k, v := range m {
-				o, err := pulumifyValue([]string{k}, v, substFunc)
-				if err != nil {
-					return err
+		options := buildResourceOptions(n)
+		// a ${resources.<alias>} reference without an output field formats the resource itself and is not a dependency
+		if dependsOn := unreferencedDependencies(n.referencingValues(), g.Dependencies[id], 3); len(dependsOn) > 0 {
+			resources := make([]jen.Code, 0, len(dependsOn))
+			for _, dep := range dependsOn {
+				if !g.Nodes[dep].Environment {
+					resources = append(resources, jen.Id(string(dep)))
 				}
-				argAssignments[toParamName(k)] = o
 			}
+			if len(resources) > 0 {
+				options = append(options, jen.Qual(DefaultPulumiPackage, "DependsOn").Call(jen.Index().Qual(DefaultPulumiPackage, "Resource").Values(resources...)))
+			}
+		}
+		if n.Workload != "" {
+			parent := workloadParentGoVar(n.Workload)
+			if !registeredParents[n.Workload] {
+				registeredParents[n.Workload] = true
+				registerArgs := []jen.Code{jen.Lit(WorkloadComponentType), jen.Lit("workload." + n.Workload), jen.Id(string(parent))}
+				// the component resource shares the name and therefore the previous names of the workload component
+				if aliases := g.Nodes[GenerateGoVar("workload."+n.Workload)].Aliases; len(aliases) > 0 {
+					registerArgs = append(registerArgs, buildAliasesOption(aliases))
+				}
+				blockParts = append(
+					blockParts,
+					jen.Id(string(parent)).Op(":=").Op("&").Qual(DefaultPulumiPackage, "ResourceState").Values(),
+					jen.If(
+						jen.Err().Op(":=").Id("ctx").Dot("RegisterComponentResource").Call(registerArgs...),
+						jen.Err().Op("!=").Nil(),
+					).Block(jen.Return(jen.Err())),
+				)
+			}
+			parentOptions := []jen.Code{jen.Qual(DefaultPulumiPackage, "Parent").Call(jen.Id(string(parent)))}
+			// components generated before workloads had a component resource were created at the top level, the alias
+			// lets Pulumi adopt those rather than replacing them under the parent
+			if len(n.UnparentedAliases) > 0 {
+				parentOptions = append(parentOptions, buildUnparentedAliasesOption(n.Name, n.UnparentedAliases))
+			}
+			options = append(parentOptions, options...)
 		}
 
-		blockParts = append(
-			blockParts,
-			jen.List(jen.Id(string(id)), jen.Err()).Op(":=").Qual(n.Package, n.Constructor).Call(jen.Id("ctx"), jen.Lit(n.Name), jen.Op("&").Qual(n.Package, n.ArgsType).Values(jen.DictFunc(func(d jen.Dict) {
-				for k, v := range argAssignments {
-					d[k] = v
+		substFunc := buildInnerSubstitutionFunc(n.Params, g.Dependencies[id])
+		if n.Template != nil {
+			statements, typeName, typeDecl, err := buildTemplateComponent(g, id, options, substFunc)
+			if err != nil {
+				return fmt.Errorf("%s: %w", n.Name, err)
+			}
+			templateTypes[typeName] = typeDecl
+			blockParts = append(blockParts, statements...)
+		} else {
+			params := mergeSecretParams(g, id)
+			argAssignments := make(jen.Dict, len(params))
+			if n.argsTypeInfo != nil {
+				d, err := pulumifyStructFields(nil, params, n.argsTypeInfo, substFunc, buildOutputTypeFunc(g, g.Dependencies[id]))
+				if err != nil {
+					// keep going so that mismatches in every component are reported together
+					typeErrs = append(typeErrs, fmt.Errorf("%s: %w", n.Name, err))
+				}
+				argAssignments = d
+			} else {
+				for k, v := range params {
+					o, err := pulumifyValue([]string{k}, v, substFunc)
+					if err != nil {
+						return err
+					}
+					argAssignments[toParamName(k)] = o
 				}
-			}))),
-			jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
-			jen.Id("_").Op("=").Id("ctx.Log.Debug").Call(jen.Lit("provisioned"), jen.Op("&").Qual(DefaultPulumiPackage, "LogArgs").Values(jen.Dict{jen.Id("Resource"): jen.Id(string(id))})),
-			jen.Line(),
-		)
+			}
+
+			blockParts = append(
+				blockParts,
+				jen.List(jen.Id(string(id)), jen.Err()).Op(":=").Qual(n.Package, n.Constructor).Call(append([]jen.Code{jen.Id("ctx"), jen.Lit(n.Name), jen.Op("&").Qual(n.Package, n.ArgsType).Values(jen.DictFunc(func(d jen.Dict) {
+					for k, v := range argAssignments {
+						d[k] = v
+					}
+				}))}, options...)...),
+				jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
+			)
+		}
+		blockParts = append(blockParts, jen.Id("_").Op("=").Id("ctx.Log.Debug").Call(jen.Lit("provisioned"), jen.Op("&").Qual(DefaultPulumiPackage, "LogArgs").Values(jen.Dict{jen.Id("Resource"): jen.Id(string(id))})))
+		// providers are not part of the stack outputs
+		if !n.IsProvider() {
+			blockParts = append(blockParts, jen.Id("ctx").Dot("Export").Call(jen.Lit(n.Name), jen.Qual(DefaultPulumiPackage, "Map").Values(buildExports(id, n.Exports, n.SecretOutputs))))
+		}
+		// the workload component depends on all of its resources so it is always the last child of its parent
+		if n.IsWorkload() {
+			blockParts = append(blockParts, jen.If(
+				jen.Err().Op(":=").Id("ctx").Dot("RegisterResourceOutputs").Call(jen.Id(string(workloadParentGoVar(n.Workload))), jen.Qual(DefaultPulumiPackage, "Map").Values()),
+				jen.Err().Op("!=").Nil(),
+			).Block(jen.Return(jen.Err())))
+		}
+		blockParts = append(blockParts, jen.Line())
 		return nil
 	}); err != nil {
 		return nil, err
+	} else if err := errors.Join(typeErrs...); err != nil {
+		return nil, err
 	}
 
-	blockParts = append(blockParts, jen.Return(jen.Nil()))
+	return append(blockParts, jen.Return(jen.Nil())), nil
+}
+
+func BuildJenFile(g ComponentGraph) (*jen.File, error) {
+	f := jen.NewFile("main")
+
+	templateTypes := make(map[string]jen.Code)
+	blockParts, err := buildProgramBlock(g, templateTypes)
+	if err != nil {
+		return nil, err
+	}
 
 	f.Func().Id("main").Params().Block(
 		jen.Qual(DefaultPulumiPackage, "Run").Call(jen.Func().Params(
@@ -423,6 +955,101 @@ func BuildJenFile(g ComponentGraph) (*jen.File, error) {
 			blockParts...,
 		)),
 	)
+	addTemplateTypes(f, templateTypes)
 
 	return f, nil
 }
+
+// addTemplateTypes adds the struct types of the template components to the file in a stable order.
+func addTemplateTypes(f *jen.File, templateTypes map[string]jen.Code) {
+	for _, name := range slices.Sorted(maps.Keys(templateTypes)) {
+		f.Line().Add(templateTypes[name])
+	}
+}
+
+// BuildStacksJenFile generates a program that selects the graph to construct by the name of the current stack. Each
+// graph becomes a function and stacks without their own graph use the default graph. Without any stack graphs this
+// is the same as BuildJenFile.
+func BuildStacksJenFile(g ComponentGraph, stacks map[string]ComponentGraph) (*jen.File, error) {
+	if len(stacks) == 0 {
+		return BuildJenFile(g)
+	}
+	f := jen.NewFile("main")
+
+	cases := make([]jen.Code, 0, len(stacks)+1)
+	funcs := make([]jen.Code, 0, len(stacks)+1)
+	templateTypes := make(map[string]jen.Code)
+	addFunc := func(name string, g ComponentGraph) error {
+		blockParts, err := buildProgramBlock(g, templateTypes)
+		if err != nil {
+			return err
+		}
+		funcs = append(funcs, jen.Func().Id(name).Params(
+			jen.Id("ctx").Op("*").Qual(DefaultPulumiPackage, "Context"),
+		).Error().Block(blockParts...))
+		return nil
+	}
+	for _, stack := range slices.Sorted(maps.Keys(stacks)) {
+		name := string(GenerateGoVar("stack." + stack))
+		if err := addFunc(name, stacks[stack]); err != nil {
+			return nil, fmt.Errorf("stack %s: %w", stack, err)
+		}
+		cases = append(cases, jen.Case(jen.Lit(stack)).Block(jen.Return(jen.Id(name).Call(jen.Id("ctx")))))
+	}
+	if err := addFunc("defaultStack", g); err != nil {
+		return nil, err
+	}
+	cases = append(cases, jen.Default().Block(jen.Return(jen.Id("defaultStack").Call(jen.Id("ctx")))))
+
+	f.Func().Id("main").Params().Block(
+		jen.Qual(DefaultPulumiPackage, "Run").Call(jen.Func().Params(
+			jen.Id("ctx").Op("*").Qual(DefaultPulumiPackage, "Context"),
+		).Error().Block(
+			jen.Switch(jen.Id("ctx").Dot("Stack").Call()).Block(cases...),
+		)),
+	)
+	for _, fn := range funcs {
+		f.Line().Add(fn)
+	}
+	addTemplateTypes(f, templateTypes)
+
+	return f, nil
+}
+
+const (
+	OutputFormatGo   = "go"
+	OutputFormatYaml = "yaml"
+)
+
+// DefaultOutputFiles are the files that the generated program is written to for each output format.
+var DefaultOutputFiles = map[string]string{
+	OutputFormatGo:   MainFile,
+	OutputFormatYaml: PulumiProjectFile,
+}
+
+// BuildProgram generates the program for the graph in the output format and returns a function that renders it. The
+// stack graphs are selected at runtime by the stack name and are only supported by the go format. The project name is
+// only used by the yaml format since the program is the project file itself.
+func BuildProgram(g ComponentGraph, stacks map[string]ComponentGraph, format string, projectName string) (func(io.Writer) error, error) {
+	switch format {
+	case OutputFormatGo, "":
+		f, err := BuildStacksJenFile(g, stacks)
+		if err != nil {
+			return nil, err
+		}
+		return f.Render, nil
+	case OutputFormatYaml:
+		if len(stacks) > 0 {
+			return nil, fmt.Errorf("the %s output format cannot select components by stack at runtime, generate for a single stack instead", OutputFormatYaml)
+		}
+		doc, err := BuildPulumiYaml(g, projectName)
+		if err != nil {
+			return nil, err
+		}
+		return func(w io.Writer) error {
+			return yaml.NewEncoder(w).Encode(doc)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format '%s', expected one of %s or %s", format, OutputFormatGo, OutputFormatYaml)
+	}
+}
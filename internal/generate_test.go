@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"maps"
+	"slices"
 	"testing"
 
 	"github.com/dave/jennifer/jen"
@@ -69,6 +71,8 @@ func TestGenerateGoVar(t *testing.T) {
 
 func ref(id string) *string { return &id }
 
+func boolRef(b bool) *bool { return &b }
+
 func TestGenerateComponentGraph_nominal(t *testing.T) {
 	cfg := ScoreConfig{
 		Workloads: []types.Workload{
@@ -131,10 +135,10 @@ func TestGenerateComponentGraph_nominal(t *testing.T) {
 	assert.Equal(t, ComponentGraph{
 		Nodes: map[ComponentGoIdentifier]ComponentInstance{
 			"sharedThingdae392ce":  {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "shared.thing", Params: map[string]interface{}{"x": "hello", "y": "foo"}, ParamsDefinedBy: "workloadFoo6d39e786"},
-			"workloadBar2eeefa0f":  {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.bar", Params: map[string]interface{}{"containers": map[string]interface{}(nil), "metadata": map[string]interface{}{"name": "bar"}}, ParamsDefinedBy: "workloadBar2eeefa0f"},
-			"workloadBarA9c79b8d6": {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.bar.a", Params: map[string]interface{}{"raw": "banana"}, ParamsDefinedBy: "workloadBar2eeefa0f"},
-			"workloadFoo6d39e786":  {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.foo", Params: map[string]interface{}{"containers": map[string]interface{}(nil), "metadata": map[string]interface{}{"name": "foo"}}, ParamsDefinedBy: "workloadFoo6d39e786"},
-			"workloadFooAc5757e5b": {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.foo.a", Params: map[string]interface{}{"plain": "${resources.b.p}", "wrapped": "before ${resources.b.p} after"}, ParamsDefinedBy: "workloadFoo6d39e786"},
+			"workloadBar2eeefa0f":  {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.bar", Params: map[string]interface{}{"containers": map[string]interface{}(nil), "metadata": map[string]interface{}{"name": "bar"}}, ParamsDefinedBy: "workloadBar2eeefa0f", Workload: "bar"},
+			"workloadBarA9c79b8d6": {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.bar.a", Params: map[string]interface{}{"raw": "banana"}, ParamsDefinedBy: "workloadBar2eeefa0f", Workload: "bar"},
+			"workloadFoo6d39e786":  {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.foo", Params: map[string]interface{}{"containers": map[string]interface{}(nil), "metadata": map[string]interface{}{"name": "foo"}}, ParamsDefinedBy: "workloadFoo6d39e786", Workload: "foo"},
+			"workloadFooAc5757e5b": {Package: "github.com/astromechza/pulumi-echo", Constructor: "NewComponent", ArgsType: "Args", Name: "workload.foo.a", Params: map[string]interface{}{"plain": "${resources.b.p}", "wrapped": "before ${resources.b.p} after"}, ParamsDefinedBy: "workloadFoo6d39e786", Workload: "foo"},
 		},
 		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
 			"workloadBar2eeefa0f":  {"a": "workloadBarA9c79b8d6", "b": "sharedThingdae392ce"},
@@ -186,6 +190,7 @@ func main() {
 			return err
 		}
 		_ = ctx.Log.Debug("provisioned", &pulumi.LogArgs{Resource: sharedThingdae392ce})
+		ctx.Export("shared.thing", pulumi.Map{"urn": sharedThingdae392ce.URN()})
 
 		return nil
 	})
@@ -193,6 +198,22 @@ func main() {
 `, f.GoString())
 }
 
+func TestBuildJenFile_exports(t *testing.T) {
+	f, err := BuildJenFile(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedDnsf4b3a5bb": {Package: "github.com/astromechza/scorpion/lib/random-subdomain", Constructor: "New", ArgsType: "Inputs", Name: "shared.dns", Exports: []string{"host", "parent_domain"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `
+		ctx.Export("shared.dns", pulumi.Map{
+			"host":          sharedDnsf4b3a5bb.Host,
+			"parent_domain": sharedDnsf4b3a5bb.ParentDomain,
+			"urn":           sharedDnsf4b3a5bb.URN(),
+		})
+`)
+}
+
 func Test_toParamName(t *testing.T) {
 	for k, v := range map[string]string{
 		"foo":        "Foo",
@@ -205,3 +226,392 @@ func Test_toParamName(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateComponentGraph_container_files(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Containers: map[string]types.Container{
+					"main": {
+						Image: "nginx",
+						Files: types.ContainerFiles{
+							"/etc/app.conf": {Content: ref("name=${metadata.name}\nhost=${resources.db.host}\nrate=100%\n")},
+							"/etc/raw.conf": {Content: ref("keep ${this} and $$ as is"), NoExpand: boolRef(true)},
+						},
+					},
+				},
+				Resources: map[string]types.Resource{
+					"db": {Type: "thing", Id: ref("db")},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "github.com/astromechza/pulumi-echo", ConstructorFunc: "NewComponent", ArgsStruct: "Args"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "github.com/astromechza/pulumi-echo", ConstructorFunc: "NewComponent", ArgsStruct: "Args"},
+				ResourceType:   "thing",
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"main": map[string]interface{}{
+			"image": "nginx",
+			"files": map[string]interface{}{
+				"/etc/app.conf": map[string]interface{}{"content": "name=foo\nhost=${resources.db.host}\nrate=100%\n"},
+				"/etc/raw.conf": map[string]interface{}{"content": "keep $${this} and $$$$ as is", "noExpand": true},
+			},
+		},
+	}, g.Nodes["workloadFoo6d39e786"].Params["containers"])
+	assert.Equal(t, "name=${metadata.name}\nhost=${resources.db.host}\nrate=100%\n", *cfg.Workloads[0].Containers["main"].Files["/etc/app.conf"].Content)
+
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `"/etc/app.conf": pulumi.Map{"content": pulumi.Sprintf("name=foo\nhost=%v\nrate=100%%\n", sharedDbf876cb74.Host)}`)
+	assert.Contains(t, f.GoString(), `"content":  pulumi.Sprintf("keep ${this} and $$ as is"),`)
+
+	t.Run("unknown resource", func(t *testing.T) {
+		cfg.Workloads[0].Containers["main"].Files["/etc/app.conf"] = types.ContainerFile{Content: ref("${resources.missing.host}")}
+		_, err := cfg.GenerateComponentGraph()
+		assert.EqualError(t, err, `containers.main.files./etc/app.conf: unknown resource alias "missing" referenced by file "/etc/app.conf" in container "main" of workload "foo"`)
+	})
+}
+
+func TestGenerateComponentGraph_container_volumes(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Containers: map[string]types.Container{
+					"main": {
+						Image: "nginx",
+						Volumes: types.ContainerVolumes{
+							"/data":  {Source: "${resources.data}"},
+							"/cache": {Source: "${resources.cache.name}", ReadOnly: boolRef(true)},
+						},
+					},
+				},
+				Resources: map[string]types.Resource{
+					"data":  {Type: "volume"},
+					"cache": {Type: "tmp-dir", Id: ref("cache")},
+					"db":    {Type: "postgres"},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "github.com/astromechza/pulumi-echo", ConstructorFunc: "NewComponent", ArgsStruct: "Args"},
+	}
+	for rt, volumeOutput := range map[string]string{"volume": "path", "tmp-dir": "dir", "postgres": ""} {
+		cfg.ResourceComponents = append(cfg.ResourceComponents, ResourceComponentEntry{ComponentEntry: cfg.DefaultWorkloadComponent, ResourceType: rt, VolumeOutput: volumeOutput})
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"/data":  map[string]interface{}{"source": outputRef("${resources.data.path}")},
+		"/cache": map[string]interface{}{"source": outputRef("${resources.cache.name}"), "readOnly": true},
+	}, g.Nodes["workloadFoo6d39e786"].Params["containers"].(map[string]interface{})["main"].(map[string]interface{})["volumes"])
+	assert.Equal(t, map[LocalAlias]ComponentGoIdentifier{"data": "workloadFooDatabde32860", "cache": "sharedCache2df2c0ec", "db": "workloadFooDb06fdf2c2"}, g.Dependencies["workloadFoo6d39e786"])
+
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `"source": workloadFooDatabde32860.Path`)
+	assert.Contains(t, f.GoString(), `"source":   sharedCache2df2c0ec.Name`)
+
+	for source, message := range map[string]string{
+		"literal":               `containers.main.volumes./data: volume source "literal" must be a single ${resources.<alias>} reference`,
+		"${metadata.name}":      `containers.main.volumes./data: volume source "${metadata.name}" must be a single ${resources.<alias>} reference`,
+		"${resources.missing}":  `containers.main.volumes./data: volume source "${resources.missing}" references an unknown resource "missing"`,
+		"${resources.db}":       `containers.main.volumes./data: volume source "${resources.db}" references resource "db" of type "postgres" whose component has no volume_output configured`,
+		"a-${resources.data}":   `containers.main.volumes./data: volume source "a-${resources.data}" must be a single ${resources.<alias>} reference`,
+		"${resources.data}${x}": `containers.main.volumes./data: volume source "${resources.data}${x}" must be a single ${resources.<alias>} reference`,
+	} {
+		t.Run(source, func(t *testing.T) {
+			cfg.Workloads[0].Containers["main"].Volumes["/data"] = types.ContainerVolume{Source: source}
+			_, err := cfg.GenerateComponentGraph()
+			assert.EqualError(t, err, message)
+		})
+	}
+}
+
+func TestGenerateStackComponentGraph(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"db": {Type: "postgres", Id: ref("db")},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/cloud", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/cloud", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs", FixedParams: map[string]interface{}{"size": "large", "tier": "gold"}},
+				ResourceType:   "postgres",
+			},
+		},
+		Stacks: map[string]StackConfig{
+			"dev": {
+				WorkloadComponent: &ComponentEntry{Package: "example.com/docker", ConstructorFunc: "NewContainer", ArgsStruct: "ContainerArgs"},
+				ResourceComponents: []ResourceComponentEntry{
+					{
+						ComponentEntry: ComponentEntry{Package: "example.com/docker", ConstructorFunc: "NewPostgres", ArgsStruct: "PostgresArgs"},
+						ResourceType:   "postgres",
+					},
+				},
+			},
+			"staging": {
+				FixedParams: map[string]map[string]interface{}{"shared.db": {"size": "small"}},
+			},
+		},
+	}
+
+	g, err := cfg.GenerateStackComponentGraph("dev")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/docker", g.Nodes["workloadFoo6d39e786"].Package)
+	assert.Equal(t, "NewContainer", g.Nodes["workloadFoo6d39e786"].Constructor)
+	assert.Equal(t, "NewPostgres", g.Nodes["sharedDbf876cb74"].Constructor)
+
+	g, err = cfg.GenerateStackComponentGraph("staging")
+	require.NoError(t, err)
+	assert.Equal(t, "NewDatabase", g.Nodes["sharedDbf876cb74"].Constructor)
+	assert.Equal(t, map[string]interface{}{"size": "small", "tier": "gold"}, g.Nodes["sharedDbf876cb74"].FixedParams)
+	assert.Equal(t, map[string]interface{}{"size": "large", "tier": "gold"}, cfg.ResourceComponents[0].FixedParams)
+
+	_, err = cfg.GenerateStackComponentGraph("prod")
+	assert.EqualError(t, err, "stack 'prod' is not defined in the stacks section of the config")
+
+	cfg.Stacks["staging"] = StackConfig{FixedParams: map[string]map[string]interface{}{"shared.cache": {"size": "small"}}}
+	_, err = cfg.GenerateStackComponentGraph("staging")
+	assert.EqualError(t, err, "stack staging: fixed params refer to unknown component 'shared.cache'")
+}
+
+func TestBuildStacksJenFile(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"db": {Type: "postgres", Id: ref("db")},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/cloud", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/cloud", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"},
+				ResourceType:   "postgres",
+			},
+		},
+		Stacks: map[string]StackConfig{
+			"dev": {
+				WorkloadComponent: &ComponentEntry{Package: "example.com/docker", ConstructorFunc: "NewContainer", ArgsStruct: "ContainerArgs"},
+				ResourceComponents: []ResourceComponentEntry{
+					{
+						ComponentEntry: ComponentEntry{Package: "example.com/docker", ConstructorFunc: "NewPostgres", ArgsStruct: "PostgresArgs"},
+						ResourceType:   "postgres",
+					},
+				},
+			},
+			"staging": {},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	stacks, err := cfg.GenerateStackComponentGraphs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "staging"}, slices.Sorted(maps.Keys(stacks)))
+
+	f, err := BuildStacksJenFile(g, stacks)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		switch ctx.Stack() {
+		case "dev":
+			return stackDev9144f282(ctx)
+		case "staging":
+			return stackStaging0d681198(ctx)
+		default:
+			return defaultStack(ctx)
+		}
+	})
+}`)
+	assert.Contains(t, out, `func stackDev9144f282(ctx *pulumi.Context) error {
+	sharedDbf876cb74, err := docker.NewPostgres(ctx, "shared.db", &docker.PostgresArgs{})`)
+	assert.Contains(t, out, `func defaultStack(ctx *pulumi.Context) error {
+	sharedDbf876cb74, err := cloud.NewDatabase(ctx, "shared.db", &cloud.DatabaseArgs{})`)
+
+	_, err = BuildProgram(g, stacks, OutputFormatYaml, "example")
+	assert.EqualError(t, err, "the yaml output format cannot select components by stack at runtime, generate for a single stack instead")
+}
+
+func TestGenerateComponentGraph_pulumi_config(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Containers: map[string]types.Container{
+					"main": {
+						Image:     "nginx",
+						Variables: map[string]string{"API_KEY": "${pulumi.secret.apiKey}"},
+						Files: map[string]types.ContainerFile{
+							"/etc/region": {Content: ref("region=${pulumi.config.aws:region}")},
+						},
+					},
+				},
+				Resources: map[string]types.Resource{
+					"db": {Type: "postgres", Params: map[string]interface{}{"size": "${pulumi.config.dbSize}"}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "github.com/astromechza/pulumi-echo", ConstructorFunc: "NewComponent", ArgsStruct: "Args"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "github.com/astromechza/pulumi-echo", ConstructorFunc: "NewComponent", ArgsStruct: "Args"},
+				ResourceType:   "postgres",
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `Size: pulumi.Sprintf("%v", config.Require(ctx, "dbSize"))`)
+	assert.Contains(t, out, `"variables": pulumi.Map{"API_KEY": pulumi.Sprintf("%v", config.RequireSecret(ctx, "apiKey"))}`)
+	assert.Contains(t, out, `"content": pulumi.Sprintf("region=%v", config.Require(ctx, "aws:region"))`)
+
+	cfg.Workloads[0].Resources["db"] = types.Resource{Type: "postgres", Params: map[string]interface{}{"size": "${pulumi.stack}"}}
+	_, err = cfg.GenerateComponentGraph()
+	assert.EqualError(t, err, "size: invalid ref 'pulumi.stack': expected pulumi.config.<key> or pulumi.secret.<key>")
+}
+
+func TestGenerateComponentGraph_providers(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"bucket": {Type: "s3", Id: ref("bucket")},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/k8s", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs", Provider: "cluster"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/aws", ConstructorFunc: "NewBucket", ArgsStruct: "BucketArgs", Provider: "east"},
+				ResourceType:   "s3",
+			},
+		},
+		Providers: map[string]ComponentEntry{
+			"east":    {Package: "github.com/pulumi/pulumi-aws/sdk/v6/go/aws", ConstructorFunc: "NewProvider", ArgsStruct: "ProviderArgs", FixedParams: map[string]interface{}{"region": "us-east-1"}},
+			"cluster": {Package: "github.com/pulumi/pulumi-kubernetes/sdk/v4/go/kubernetes", ConstructorFunc: "NewProvider", ArgsStruct: "ProviderArgs"},
+			"unused":  {Package: "github.com/pulumi/pulumi-aws/sdk/v6/go/aws", ConstructorFunc: "NewProvider", ArgsStruct: "ProviderArgs"},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	assert.Len(t, g.Nodes, 4)
+	assert.Equal(t, GenerateGoVar("provider.east"), g.Nodes["sharedBucket6a6b7298"].Provider)
+	assert.Equal(t, GenerateGoVar("provider.cluster"), g.Nodes["workloadFoo6d39e786"].Provider)
+
+	visited := make([]string, 0)
+	require.NoError(t, g.VisitInDependencyOrder(func(id ComponentGoIdentifier) error {
+		visited = append(visited, g.Nodes[id].Name)
+		return nil
+	}))
+	assert.Equal(t, []string{"provider.cluster", "provider.east", "shared.bucket", "workload.foo"}, visited)
+
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `providerEast473a3e3f, err := aws.NewProvider(ctx, "provider.east", &aws.ProviderArgs{Region: pulumi.String("us-east-1")})`)
+	assert.Contains(t, out, `sharedBucket6a6b7298, err := aws1.NewBucket(ctx, "shared.bucket", &aws1.BucketArgs{}, pulumi.Provider(providerEast473a3e3f))`)
+	assert.NotContains(t, out, `ctx.Export("provider.east"`)
+
+	cfg.ResourceComponents[0].Provider = "west"
+	_, err = cfg.GenerateComponentGraph()
+	assert.EqualError(t, err, "resource 'shared.bucket': component references unknown provider 'west'")
+
+	cfg.Providers["east"] = ComponentEntry{Package: "example.com/aws", ConstructorFunc: "NewProvider", ArgsStruct: "ProviderArgs", Provider: "unused"}
+	_, err = cfg.GenerateComponentGraph()
+	assert.EqualError(t, err, "config contains an invalid provider spec 'east': providers cannot reference another provider")
+}
+
+func TestBuildJenFile_workload_parents(t *testing.T) {
+	g := ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedThingdae392ce":  {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "shared.thing"},
+			"workloadFooAc5757e5b": {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "workload.foo.a", Workload: "foo"},
+			"workloadFoo6d39e786":  {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "workload.foo", Workload: "foo"},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"workloadFoo6d39e786": {"a": "workloadFooAc5757e5b", "b": "sharedThingdae392ce"},
+		},
+	}
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	assert.Equal(t, `package main
+
+import (
+	echo "example.com/echo"
+	pulumi "github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		sharedThingdae392ce, err := echo.New(ctx, "shared.thing", &echo.Args{})
+		if err != nil {
+			return err
+		}
+		_ = ctx.Log.Debug("provisioned", &pulumi.LogArgs{Resource: sharedThingdae392ce})
+		ctx.Export("shared.thing", pulumi.Map{"urn": sharedThingdae392ce.URN()})
+
+		scorpionWorkloadFooe2ad97b9 := &pulumi.ResourceState{}
+		if err := ctx.RegisterComponentResource("scorpion:workload", "workload.foo", scorpionWorkloadFooe2ad97b9); err != nil {
+			return err
+		}
+		workloadFooAc5757e5b, err := echo.New(ctx, "workload.foo.a", &echo.Args{}, pulumi.Parent(scorpionWorkloadFooe2ad97b9))
+		if err != nil {
+			return err
+		}
+		_ = ctx.Log.Debug("provisioned", &pulumi.LogArgs{Resource: workloadFooAc5757e5b})
+		ctx.Export("workload.foo.a", pulumi.Map{"urn": workloadFooAc5757e5b.URN()})
+
+		workloadFoo6d39e786, err := echo.New(ctx, "workload.foo", &echo.Args{}, pulumi.Parent(scorpionWorkloadFooe2ad97b9), pulumi.DependsOn([]pulumi.Resource{sharedThingdae392ce, workloadFooAc5757e5b}))
+		if err != nil {
+			return err
+		}
+		_ = ctx.Log.Debug("provisioned", &pulumi.LogArgs{Resource: workloadFoo6d39e786})
+		ctx.Export("workload.foo", pulumi.Map{"urn": workloadFoo6d39e786.URN()})
+		if err := ctx.RegisterResourceOutputs(scorpionWorkloadFooe2ad97b9, pulumi.Map{}); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+`, f.GoString())
+}
+
+func TestBuildJenFile_depends_on(t *testing.T) {
+	f, err := BuildJenFile(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedA": {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "shared.a"},
+			"sharedB": {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "shared.b"},
+			"sharedC": {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "shared.c"},
+			"sharedD": {Package: "example.com/echo", Constructor: "New", ArgsType: "Args", Name: "shared.d", Params: map[string]interface{}{
+				"list":    []interface{}{"${resources.a.x}"},
+				"escaped": "$${resources.c.x}",
+				"bare":    "${resources.b}",
+			}},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"sharedD": {"a": "sharedA", "a2": "sharedA", "b": "sharedB", "c": "sharedC"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `}, pulumi.DependsOn([]pulumi.Resource{sharedB, sharedC}))`)
+}
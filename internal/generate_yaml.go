@@ -0,0 +1,371 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/score-spec/score-go/framework"
+	"gopkg.in/yaml.v3"
+)
+
+// buildYamlSubstitutionFunc returns a substitution function that converts ${resources.x.y} references into Pulumi yaml
+// interpolations of the dependency's outputs and inlines metadata values. Stack config references become
+// interpolations of the config key and are recorded in configKeys along with whether they are secret.
+func buildYamlSubstitutionFunc(metadata map[string]interface{}, dependencies map[LocalAlias]ComponentGoIdentifier, configKeys map[string]bool) func(s string) (string, error) {
+	metadataLookup := mapLookupOutput(metadata)
+	return func(ref string) (string, error) {
+		parts := framework.SplitRefParts(ref)
+		switch parts[0] {
+		case "metadata":
+			if len(parts) < 2 {
+				return "", fmt.Errorf("invalid ref '%s': requires at least a metadata key to lookup", ref)
+			}
+			rv, err := metadataLookup(parts[1:]...)
+			if err != nil {
+				return "", fmt.Errorf("invalid ref '%s': %w", ref, err)
+			}
+			return escapeYamlInterpolation(fmt.Sprintf("%v", rv)), nil
+		case "resources":
+			if len(parts) < 2 {
+				return "", fmt.Errorf("invalid ref '%s': requires at least a resource name to lookup", ref)
+			}
+			rv, ok := dependencies[LocalAlias(parts[1])]
+			if !ok {
+				return "", fmt.Errorf("invalid ref '%s': no known resource '%s'", ref, parts[1])
+			}
+			return "${" + strings.Join(append([]string{string(rv)}, parts[2:]...), ".") + "}", nil
+		case "pulumi":
+			key, secret, err := parsePulumiConfigRef(ref)
+			if err != nil {
+				return "", err
+			}
+			configKeys[key] = configKeys[key] || secret
+			return "${" + key + "}", nil
+		default:
+			return "", fmt.Errorf("invalid ref '%s': unknown reference root, use $$ to escape the substitution", ref)
+		}
+	}
+}
+
+// escapeYamlInterpolation escapes text that Pulumi yaml would otherwise treat as an interpolation.
+func escapeYamlInterpolation(s string) string {
+	return strings.ReplaceAll(s, "${", "$${")
+}
+
+// yamlifyValue converts a param value into its Pulumi yaml equivalent, substituting any placeholders in strings.
+func yamlifyValue(path []string, raw interface{}, substituter *framework.Substituter) (interface{}, error) {
+	switch typed := raw.(type) {
+	case secretValue:
+		v, err := yamlifyValue(path, typed.value, substituter)
+		if err != nil {
+			return nil, err
+		}
+		return yamlSecret(v), nil
+	case outputRef:
+		return yamlifyValue(path, string(typed), substituter)
+	case string:
+		if strings.Contains(typed, "${") {
+			v, err := substituter.SubstituteString(typed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to substitute %q at %s: %w", typed, strings.Join(path, "."), err)
+			}
+			return v, nil
+		}
+		return typed, nil
+	case []interface{}:
+		out := make([]interface{}, 0, len(typed))
+		for i, v := range typed {
+			o, err := yamlifyValue(append(path, fmt.Sprintf("[%d]", i)), v, substituter)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, o)
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			o, err := yamlifyValue(append(path, k), v, substituter)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = o
+		}
+		return out, nil
+	default:
+		return typed, nil
+	}
+}
+
+// yamlSecret wraps a value with the Pulumi yaml secret builtin.
+func yamlSecret(v interface{}) map[string]interface{} {
+	return map[string]interface{}{"fn::secret": v}
+}
+
+// escapeFixedValue escapes interpolations in fixed params since these are not subject to substitution.
+func escapeFixedValue(raw interface{}) interface{} {
+	switch typed := raw.(type) {
+	case string:
+		return escapeYamlInterpolation(typed)
+	case []interface{}:
+		out := make([]interface{}, 0, len(typed))
+		for _, v := range typed {
+			out = append(out, escapeFixedValue(v))
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			out[k] = escapeFixedValue(v)
+		}
+		return out
+	default:
+		return typed
+	}
+}
+
+// appendYamlMapping appends the key and the encoded value to the mapping node.
+func appendYamlMapping(mapping *yaml.Node, key string, value interface{}) error {
+	valueNode := new(yaml.Node)
+	if err := valueNode.Encode(value); err != nil {
+		return err
+	}
+	mapping.Content = append(mapping.Content, yamlKey(key), valueNode)
+	return nil
+}
+
+func yamlKey(key string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+}
+
+// BuildPulumiYaml builds a Pulumi yaml program (the contents of a Pulumi.yaml file) for the graph. Resources are
+// declared in dependency order and use the yaml_type of their component entry. Unlike the go program, workload
+// resources stay at the top level since Pulumi yaml cannot declare the component resource that would parent them.
+func BuildPulumiYaml(g ComponentGraph, projectName string) (*yaml.Node, error) {
+	variables := &yaml.Node{Kind: yaml.MappingNode}
+	resources := &yaml.Node{Kind: yaml.MappingNode}
+	outputs := &yaml.Node{Kind: yaml.MappingNode}
+	configKeys := make(map[string]bool)
+	if err := g.VisitInDependencyOrder(func(id ComponentGoIdentifier) error {
+		n := g.Nodes[id]
+		if n.Environment {
+			return appendYamlEnvironmentVariable(g, variables, id, configKeys)
+		} else if n.YamlType == "" && n.Template == nil {
+			return fmt.Errorf("component %s (%s) has no yaml_type which is required by the yaml output format", n.Name, n.Package)
+		}
+
+		substituter := &framework.Substituter{
+			Replacer: buildYamlSubstitutionFunc(n.Params, g.Dependencies[id], configKeys),
+			// keep escaped placeholders escaped since Pulumi yaml uses the same $${ escape sequence
+			UnEscaper: func(s string) (string, error) {
+				if s == "$$" {
+					return "$", nil
+				}
+				return s, nil
+			},
+		}
+		isSecretRef := buildSecretRefChecker(g, g.Dependencies[id])
+		if n.Template != nil {
+			return appendYamlTemplateVariable(variables, outputs, id, n, markSecretRefs(n.TemplateOutputs, isSecretRef), substituter)
+		}
+		properties := make(map[string]interface{}, len(n.Params)+len(n.FixedParams))
+		for k, v := range n.Params {
+			o, err := yamlifyValue([]string{k}, markSecretRefs(v, isSecretRef), substituter)
+			if err != nil {
+				return err
+			}
+			properties[k] = o
+		}
+		for k, v := range n.FixedParams {
+			if slices.Contains(n.SecretFixedParams, k) {
+				properties[k] = yamlSecret(escapeFixedValue(v))
+			} else {
+				properties[k] = escapeFixedValue(v)
+			}
+		}
+
+		resource := map[string]interface{}{"type": n.YamlType, "name": n.Name}
+		if len(properties) > 0 {
+			resource["properties"] = properties
+		}
+		options := yamlResourceOptions(n)
+		// any interpolation of a resource, even without an output field, is a dependency in Pulumi yaml
+		if dependsOn := unreferencedDependencies(n.Params, g.Dependencies[id], 2); len(dependsOn) > 0 {
+			refs := make([]string, 0, len(dependsOn))
+			for _, dep := range dependsOn {
+				// template and environment components are variables rather than resources
+				if g.Nodes[dep].Template == nil && !g.Nodes[dep].Environment {
+					refs = append(refs, "${"+string(dep)+"}")
+				}
+			}
+			if len(refs) > 0 && options == nil {
+				options = make(map[string]interface{})
+			}
+			if len(refs) > 0 {
+				options["dependsOn"] = refs
+			}
+		}
+		if options != nil {
+			resource["options"] = options
+		}
+		if err := appendYamlMapping(resources, string(id), resource); err != nil {
+			return err
+		}
+
+		if n.IsProvider() {
+			return nil
+		}
+		exports := yamlExports(id, n)
+		exports["urn"] = "${" + string(id) + ".urn}"
+		return appendYamlMapping(outputs, n.Name, exports)
+	}); err != nil {
+		return nil, err
+	}
+
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	if err := appendYamlMapping(doc, "name", projectName); err != nil {
+		return nil, err
+	} else if err := appendYamlMapping(doc, "runtime", "yaml"); err != nil {
+		return nil, err
+	}
+	if len(configKeys) > 0 {
+		config := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range slices.Sorted(maps.Keys(configKeys)) {
+			// keys in the namespace of another package are referenced without being declared by the project
+			if strings.Contains(key, ":") {
+				continue
+			}
+			decl := map[string]interface{}{"type": "string"}
+			if configKeys[key] {
+				decl["secret"] = true
+			}
+			if err := appendYamlMapping(config, key, decl); err != nil {
+				return nil, err
+			}
+		}
+		if len(config.Content) > 0 {
+			doc.Content = append(doc.Content, yamlKey("config"), config)
+		}
+	}
+	if len(variables.Content) > 0 {
+		doc.Content = append(doc.Content, yamlKey("variables"), variables)
+	}
+	if len(resources.Content) > 0 || len(outputs.Content) > 0 {
+		doc.Content = append(doc.Content, yamlKey("resources"), resources, yamlKey("outputs"), outputs)
+	}
+	return doc, nil
+}
+
+// appendYamlTemplateVariable declares the outputs of a template component as a variable, since Pulumi yaml cannot
+// declare the component resource that holds them in the go program, and exports its allow-listed output fields.
+func appendYamlTemplateVariable(variables, outputs *yaml.Node, id ComponentGoIdentifier, n ComponentInstance, values interface{}, substituter *framework.Substituter) error {
+	v, err := yamlifyValue(nil, values, substituter)
+	if err != nil {
+		return err
+	} else if err := appendYamlMapping(variables, string(id), v); err != nil {
+		return err
+	}
+	if len(n.Exports) == 0 {
+		return nil
+	}
+	return appendYamlMapping(outputs, n.Name, yamlExports(id, n))
+}
+
+// yamlExports returns the stack output map of the allow-listed output fields of a component. Secret output fields are
+// exported as secrets.
+func yamlExports(id ComponentGoIdentifier, n ComponentInstance) map[string]interface{} {
+	exports := make(map[string]interface{}, len(n.Exports)+1)
+	for _, field := range n.Exports {
+		if slices.Contains(n.SecretOutputs, field) {
+			exports[field] = yamlSecret("${" + string(id) + "." + field + "}")
+		} else {
+			exports[field] = "${" + string(id) + "." + field + "}"
+		}
+	}
+	return exports
+}
+
+// generatedYamlSections are the top-level sections of a Pulumi yaml project file that the generator owns.
+var generatedYamlSections = []string{"variables", "resources", "outputs"}
+
+// MergePulumiYaml returns a renderer that merges the generated program into the existing project file. The sections
+// that the generator owns are replaced, config declarations are merged by key, and every other key such as the name,
+// description, or options is kept. The runtime is only replaced when it is not already yaml.
+func MergePulumiYaml(existing []byte, render func(io.Writer) error) func(io.Writer) error {
+	return func(w io.Writer) error {
+		buff := new(bytes.Buffer)
+		if err := render(buff); err != nil {
+			return err
+		}
+		var doc, generated yaml.Node
+		if err := yaml.Unmarshal(existing, &doc); err != nil {
+			return fmt.Errorf("failed to decode the existing project file: %w", err)
+		} else if len(doc.Content) == 0 {
+			_, err := w.Write(buff.Bytes())
+			return err
+		} else if doc.Content[0].Kind != yaml.MappingNode {
+			return fmt.Errorf("failed to decode the existing project file: expected a mapping")
+		} else if err := yaml.Unmarshal(buff.Bytes(), &generated); err != nil {
+			return err
+		}
+		root, generatedRoot := doc.Content[0], generated.Content[0]
+
+		if runtime := yamlMappingValue(root, "runtime"); runtime == nil || !isYamlRuntime(runtime) {
+			setYamlMappingValue(root, "runtime", yamlMappingValue(generatedRoot, "runtime"))
+		}
+		if generatedConfig := yamlMappingValue(generatedRoot, "config"); generatedConfig != nil {
+			config := yamlMappingValue(root, "config")
+			if config == nil || config.Kind != yaml.MappingNode {
+				config = &yaml.Node{Kind: yaml.MappingNode}
+				setYamlMappingValue(root, "config", config)
+			}
+			for i := 0; i < len(generatedConfig.Content); i += 2 {
+				setYamlMappingValue(config, generatedConfig.Content[i].Value, generatedConfig.Content[i+1])
+			}
+		}
+		for _, section := range generatedYamlSections {
+			setYamlMappingValue(root, section, yamlMappingValue(generatedRoot, section))
+		}
+		return yaml.NewEncoder(w).Encode(&doc)
+	}
+}
+
+// isYamlRuntime returns true if the runtime of a project file, either a name or a mapping with a name, is yaml.
+func isYamlRuntime(runtime *yaml.Node) bool {
+	if runtime.Kind == yaml.MappingNode {
+		runtime = yamlMappingValue(runtime, "name")
+	}
+	return runtime != nil && runtime.Value == "yaml"
+}
+
+// yamlMappingValue returns the value of the key in the mapping node, or nil if the key is not present.
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setYamlMappingValue replaces the value of the key in the mapping node in place, appends the key if it is not
+// present, or removes the key if the value is nil.
+func setYamlMappingValue(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			if value == nil {
+				mapping.Content = slices.Delete(mapping.Content, i, i+2)
+			} else {
+				mapping.Content[i+1] = value
+			}
+			return
+		}
+	}
+	if value != nil {
+		mapping.Content = append(mapping.Content, yamlKey(key), value)
+	}
+}
@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildPulumiYaml(t *testing.T) {
+	g := ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedThingdae392ce": {YamlType: "echo:index:Echo", Name: "shared.thing", Params: map[string]interface{}{
+				"x": "hello",
+				"y": 42,
+				"w": []interface{}{"a", "$${escaped}"},
+			}, FixedParams: map[string]interface{}{"fixed": "${not.substituted}"}, Exports: []string{"x"}},
+			"workloadFoo6d39e786": {YamlType: "echo:index:Workload", Name: "workload.foo", Params: map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "foo"},
+				"env":      "db is at ${resources.b.x} and costs 100%",
+			}},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"workloadFoo6d39e786": {"b": "sharedThingdae392ce"},
+		},
+	}
+	render, err := BuildProgram(g, nil, OutputFormatYaml, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, render(buff))
+	assert.Equal(t, `name: example
+runtime: yaml
+resources:
+    sharedThingdae392ce:
+        name: shared.thing
+        properties:
+            fixed: $${not.substituted}
+            w:
+                - a
+                - $${escaped}
+            x: hello
+            "y": 42
+        type: echo:index:Echo
+    workloadFoo6d39e786:
+        name: workload.foo
+        properties:
+            env: db is at ${sharedThingdae392ce.x} and costs 100%
+            metadata:
+                name: foo
+        type: echo:index:Workload
+outputs:
+    shared.thing:
+        urn: ${sharedThingdae392ce.urn}
+        x: ${sharedThingdae392ce.x}
+    workload.foo:
+        urn: ${workloadFoo6d39e786.urn}
+`, buff.String())
+}
+
+func TestBuildPulumiYaml_missing_type(t *testing.T) {
+	_, err := BuildPulumiYaml(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedThingdae392ce": {Package: "example.com/echo", Name: "shared.thing"},
+		},
+	}, "example")
+	assert.EqualError(t, err, "component shared.thing (example.com/echo) has no yaml_type which is required by the yaml output format")
+}
+
+func TestBuildProgram_unknown_format(t *testing.T) {
+	_, err := BuildProgram(ComponentGraph{}, nil, "json", "example")
+	assert.EqualError(t, err, "unknown output format 'json', expected one of go or yaml")
+}
+
+func TestBuildPulumiYaml_pulumi_config(t *testing.T) {
+	doc, err := BuildPulumiYaml(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedThingdae392ce": {YamlType: "echo:index:Echo", Name: "shared.thing", Params: map[string]interface{}{
+				"size":   "${pulumi.config.size}",
+				"key":    "key=${pulumi.secret.apiKey}",
+				"region": "${pulumi.config.aws:region}",
+			}},
+		},
+	}, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Equal(t, `name: example
+runtime: yaml
+config:
+    apiKey:
+        secret: true
+        type: string
+    size:
+        type: string
+resources:
+    sharedThingdae392ce:
+        name: shared.thing
+        properties:
+            key: key=${apiKey}
+            region: ${aws:region}
+            size: ${size}
+        type: echo:index:Echo
+outputs:
+    shared.thing:
+        urn: ${sharedThingdae392ce.urn}
+`, buff.String())
+}
+
+func TestBuildPulumiYaml_providers(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"bucket": {Type: "s3", Id: ref("bucket")},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/k8s", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs", YamlType: "k8s:index:Workload"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/aws", ConstructorFunc: "NewBucket", ArgsStruct: "BucketArgs", YamlType: "aws:s3:Bucket", Provider: "east"},
+				ResourceType:   "s3",
+			},
+		},
+		Providers: map[string]ComponentEntry{
+			"east": {Package: "github.com/pulumi/pulumi-aws/sdk/v6/go/aws", ConstructorFunc: "NewProvider", ArgsStruct: "ProviderArgs", YamlType: "pulumi:providers:aws", FixedParams: map[string]interface{}{"region": "us-east-1"}},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	doc, err := BuildPulumiYaml(g, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Contains(t, buff.String(), `    providerEast473a3e3f:
+        name: provider.east
+        properties:
+            region: us-east-1
+        type: pulumi:providers:aws
+`)
+	assert.Contains(t, buff.String(), `        options:
+            provider: ${providerEast473a3e3f}
+`)
+	assert.NotContains(t, buff.String(), "    provider.east:\n")
+}
+
+func TestBuildPulumiYaml_depends_on(t *testing.T) {
+	doc, err := BuildPulumiYaml(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedA": {YamlType: "echo:index:Echo", Name: "shared.a"},
+			"sharedB": {YamlType: "echo:index:Echo", Name: "shared.b"},
+			"sharedC": {YamlType: "echo:index:Echo", Name: "shared.c"},
+			"sharedD": {YamlType: "echo:index:Echo", Name: "shared.d", Params: map[string]interface{}{
+				"list":    []interface{}{"${resources.a.x}"},
+				"escaped": "$${resources.c.x}",
+				"bare":    "${resources.b}",
+			}},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"sharedD": {"a": "sharedA", "a2": "sharedA", "b": "sharedB", "c": "sharedC"},
+		},
+	}, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Contains(t, buff.String(), `        options:
+            dependsOn:
+                - ${sharedC}
+`)
+}
+
+func TestMergePulumiYaml(t *testing.T) {
+	g := ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedThingdae392ce": {YamlType: "echo:index:Echo", Name: "shared.thing", Params: map[string]interface{}{"size": "${pulumi.config.size}"}},
+		},
+	}
+	render, err := BuildProgram(g, nil, OutputFormatYaml, "example")
+	require.NoError(t, err)
+	existing := `# my project
+name: my-project
+description: keep me
+runtime:
+    name: go
+    options:
+        binary: bin/example
+config:
+    region:
+        type: string
+resources:
+    oldThing:
+        type: echo:index:Echo
+outputs:
+    old: ${oldThing.urn}
+options:
+    refresh: always
+`
+	buff := new(bytes.Buffer)
+	require.NoError(t, MergePulumiYaml([]byte(existing), render)(buff))
+	assert.Equal(t, `# my project
+name: my-project
+description: keep me
+runtime: yaml
+config:
+    region:
+        type: string
+    size:
+        type: string
+resources:
+    sharedThingdae392ce:
+        name: shared.thing
+        properties:
+            size: ${size}
+        type: echo:index:Echo
+outputs:
+    shared.thing:
+        urn: ${sharedThingdae392ce.urn}
+options:
+    refresh: always
+`, buff.String())
+
+	t.Run("keeps a yaml runtime and removes empty sections", func(t *testing.T) {
+		render, err := BuildProgram(ComponentGraph{}, nil, OutputFormatYaml, "example")
+		require.NoError(t, err)
+		buff := new(bytes.Buffer)
+		require.NoError(t, MergePulumiYaml([]byte("name: x\nruntime:\n    name: yaml\nvariables:\n    a: b\n"), render)(buff))
+		assert.Equal(t, "name: x\nruntime:\n    name: yaml\n", buff.String())
+	})
+
+	t.Run("invalid existing file", func(t *testing.T) {
+		assert.EqualError(t, MergePulumiYaml([]byte("- a\n"), render)(new(bytes.Buffer)), "failed to decode the existing project file: expected a mapping")
+	})
+}
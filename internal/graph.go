@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GraphWriters are the supported output formats for rendering a component graph.
+var GraphWriters = map[string]func(io.Writer, ComponentGraph) error{
+	"dot":     WriteGraphDot,
+	"mermaid": WriteGraphMermaid,
+	"json":    WriteGraphJson,
+}
+
+// IsShared returns true if the component provisions a shared resource rather than a workload or workload resource.
+func (c ComponentInstance) IsShared() bool {
+	return strings.HasPrefix(c.Name, "shared.")
+}
+
+// IsProvider returns true if the component is a provider from the providers section of the config.
+func (c ComponentInstance) IsProvider() bool {
+	return strings.HasPrefix(c.Name, "provider.")
+}
+
+// IsWorkload returns true if the component provisions a workload rather than one of its resources.
+func (c ComponentInstance) IsWorkload() bool {
+	return c.Workload != "" && c.Name == "workload."+c.Workload
+}
+
+// hasConstructor returns true if the component is constructed from its package rather than generated within the
+// program like template and environment components.
+func (c ComponentInstance) hasConstructor() bool {
+	return c.Template == nil && !c.Environment
+}
+
+// constructorLabel returns the constructor of the component for display, or template or environment for the
+// components that are generated within the program.
+func (c ComponentInstance) constructorLabel() string {
+	if c.Template != nil {
+		return "template"
+	} else if c.Environment {
+		return EnvironmentResourceType
+	}
+	return c.Package + "." + c.Constructor
+}
+
+// providerEdgeAlias is the label of the edge from a component to the provider it is constructed with.
+const providerEdgeAlias LocalAlias = "provider"
+
+// sortedEdges calls the visit function for each dependency in the graph, including the provider of each component,
+// in a stable order.
+func (g *ComponentGraph) sortedEdges(visit func(from ComponentGoIdentifier, alias LocalAlias, to ComponentGoIdentifier)) {
+	for _, from := range slices.Sorted(maps.Keys(g.Nodes)) {
+		if p := g.Nodes[from].Provider; p != "" {
+			visit(from, providerEdgeAlias, p)
+		}
+		for _, alias := range slices.Sorted(maps.Keys(g.Dependencies[from])) {
+			visit(from, alias, g.Dependencies[from][alias])
+		}
+	}
+}
+
+// WriteGraphDot writes the graph in Graphviz DOT format with an edge from each component to its dependencies.
+func WriteGraphDot(w io.Writer, g ComponentGraph) error {
+	sb := new(strings.Builder)
+	sb.WriteString("digraph components {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	for _, id := range slices.Sorted(maps.Keys(g.Nodes)) {
+		n := g.Nodes[id]
+		attrs := ""
+		if n.IsShared() {
+			attrs = ` style="rounded,filled" fillcolor="lightblue"`
+		}
+		_, _ = fmt.Fprintf(sb, "\t%s [label=%s%s];\n", id, strconv.Quote(n.Name+"\n"+n.constructorLabel()), attrs)
+	}
+	g.sortedEdges(func(from ComponentGoIdentifier, alias LocalAlias, to ComponentGoIdentifier) {
+		_, _ = fmt.Fprintf(sb, "\t%s -> %s [label=%s];\n", from, to, strconv.Quote(string(alias)))
+	})
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteGraphMermaid writes the graph as a Mermaid flowchart with an edge from each component to its dependencies.
+func WriteGraphMermaid(w io.Writer, g ComponentGraph) error {
+	escape := strings.NewReplacer(`"`, "#quot;").Replace
+	sb := new(strings.Builder)
+	sb.WriteString("flowchart LR\n")
+	for _, id := range slices.Sorted(maps.Keys(g.Nodes)) {
+		n := g.Nodes[id]
+		label := escape(n.Name) + "<br/>" + escape(n.constructorLabel())
+		if n.IsShared() {
+			_, _ = fmt.Fprintf(sb, "\t%s([\"%s\"]):::shared\n", id, label)
+		} else {
+			_, _ = fmt.Fprintf(sb, "\t%s[\"%s\"]\n", id, label)
+		}
+	}
+	g.sortedEdges(func(from ComponentGoIdentifier, alias LocalAlias, to ComponentGoIdentifier) {
+		_, _ = fmt.Fprintf(sb, "\t%s -->|\"%s\"| %s\n", from, escape(string(alias)), to)
+	})
+	sb.WriteString("\tclassDef shared fill:lightblue\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type graphJsonNode struct {
+	Id          ComponentGoIdentifier `json:"id"`
+	Name        string                `json:"name"`
+	Package     string                `json:"package"`
+	Constructor string                `json:"constructor"`
+	ArgsType    string                `json:"args_type"`
+	Shared      bool                  `json:"shared"`
+}
+
+type graphJsonEdge struct {
+	From  ComponentGoIdentifier `json:"from"`
+	To    ComponentGoIdentifier `json:"to"`
+	Alias LocalAlias            `json:"alias"`
+}
+
+type graphJson struct {
+	Nodes []graphJsonNode `json:"nodes"`
+	Edges []graphJsonEdge `json:"edges"`
+}
+
+// WriteGraphJson writes the graph as a json document containing a list of nodes and a list of edges.
+func WriteGraphJson(w io.Writer, g ComponentGraph) error {
+	out := graphJson{Nodes: make([]graphJsonNode, 0, len(g.Nodes)), Edges: make([]graphJsonEdge, 0)}
+	for _, id := range slices.Sorted(maps.Keys(g.Nodes)) {
+		n := g.Nodes[id]
+		out.Nodes = append(out.Nodes, graphJsonNode{
+			Id: id, Name: n.Name, Package: n.Package, Constructor: n.Constructor, ArgsType: n.ArgsType, Shared: n.IsShared(),
+		})
+	}
+	g.sortedEdges(func(from ComponentGoIdentifier, alias LocalAlias, to ComponentGoIdentifier) {
+		out.Edges = append(out.Edges, graphJsonEdge{From: from, To: to, Alias: alias})
+	})
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(out)
+}
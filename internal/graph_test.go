@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exampleGraph = ComponentGraph{
+	Nodes: map[ComponentGoIdentifier]ComponentInstance{
+		"sharedDbf876cb74":    {Package: "example.com/postgres", Constructor: "New", ArgsType: "Args", Name: "shared.db"},
+		"workloadFoo6d39e786": {Package: "example.com/workload", Constructor: "New", ArgsType: "Args", Name: "workload.foo"},
+	},
+	Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+		"workloadFoo6d39e786": {"db": "sharedDbf876cb74"},
+	},
+}
+
+func TestWriteGraphDot(t *testing.T) {
+	buff := new(bytes.Buffer)
+	require.NoError(t, WriteGraphDot(buff, exampleGraph))
+	assert.Equal(t, `digraph components {
+	rankdir=LR;
+	node [shape=box];
+	sharedDbf876cb74 [label="shared.db\nexample.com/postgres.New" style="rounded,filled" fillcolor="lightblue"];
+	workloadFoo6d39e786 [label="workload.foo\nexample.com/workload.New"];
+	workloadFoo6d39e786 -> sharedDbf876cb74 [label="db"];
+}
+`, buff.String())
+}
+
+func TestWriteGraphMermaid(t *testing.T) {
+	buff := new(bytes.Buffer)
+	require.NoError(t, WriteGraphMermaid(buff, exampleGraph))
+	assert.Equal(t, `flowchart LR
+	sharedDbf876cb74(["shared.db<br/>example.com/postgres.New"]):::shared
+	workloadFoo6d39e786["workload.foo<br/>example.com/workload.New"]
+	workloadFoo6d39e786 -->|"db"| sharedDbf876cb74
+	classDef shared fill:lightblue
+`, buff.String())
+}
+
+func TestWriteGraphJson(t *testing.T) {
+	buff := new(bytes.Buffer)
+	require.NoError(t, WriteGraphJson(buff, exampleGraph))
+	assert.JSONEq(t, `{
+  "nodes": [
+    {"id": "sharedDbf876cb74", "name": "shared.db", "package": "example.com/postgres", "constructor": "New", "args_type": "Args", "shared": true},
+    {"id": "workloadFoo6d39e786", "name": "workload.foo", "package": "example.com/workload", "constructor": "New", "args_type": "Args", "shared": false}
+  ],
+  "edges": [
+    {"from": "workloadFoo6d39e786", "to": "sharedDbf876cb74", "alias": "db"}
+  ]
+}`, buff.String())
+}
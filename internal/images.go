@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/score-spec/score-go/types"
+)
+
+// PlaceholderImage is the Score image value that refers to the image built alongside the workload.
+const PlaceholderImage = "."
+
+// UnresolvedPlaceholderImagesError is returned by ResolvePlaceholderImages when no image was given for some of the
+// placeholder containers.
+type UnresolvedPlaceholderImagesError struct {
+	Containers []string
+}
+
+func (e *UnresolvedPlaceholderImagesError) Error() string {
+	return fmt.Sprintf("no image was given for the '%s' placeholder image of containers: %s", PlaceholderImage, strings.Join(e.Containers, ", "))
+}
+
+// ResolvePlaceholderImages replaces the placeholder images of the workload containers with the given images. Each
+// image is either a ref which applies to every placeholder container, or container=ref which applies to the named
+// container and takes precedence. Placeholders that remain unresolved are an error unless allowUnresolved is set.
+func ResolvePlaceholderImages(spec *types.Workload, images []string, allowUnresolved bool) error {
+	var defaultImage string
+	byContainer := make(map[string]string, len(images))
+	for _, raw := range images {
+		if name, ref, ok := strings.Cut(raw, "="); ok {
+			if name == "" || ref == "" {
+				return fmt.Errorf("invalid image '%s': expected ref or container=ref", raw)
+			} else if _, ok := byContainer[name]; ok {
+				return fmt.Errorf("image for container '%s' was specified more than once", name)
+			} else if c, ok := spec.Containers[name]; !ok {
+				return fmt.Errorf("image for container '%s' does not match any container of the workload", name)
+			} else if c.Image != PlaceholderImage {
+				return fmt.Errorf("image for container '%s' cannot replace the non-placeholder image '%s'", name, c.Image)
+			}
+			byContainer[name] = ref
+		} else if raw == "" {
+			return fmt.Errorf("invalid image '%s': expected ref or container=ref", raw)
+		} else if defaultImage != "" {
+			return fmt.Errorf("only one image without a container name may be specified")
+		} else {
+			defaultImage = raw
+		}
+	}
+
+	unresolved := make([]string, 0)
+	for _, name := range slices.Sorted(maps.Keys(spec.Containers)) {
+		c := spec.Containers[name]
+		if c.Image != PlaceholderImage {
+			continue
+		}
+		if c.Image = cmp.Or(byContainer[name], defaultImage); c.Image == "" {
+			if !allowUnresolved {
+				unresolved = append(unresolved, name)
+			}
+			continue
+		}
+		spec.Containers[name] = c
+	}
+	if len(unresolved) > 0 {
+		return &UnresolvedPlaceholderImagesError{Containers: unresolved}
+	}
+	return nil
+}
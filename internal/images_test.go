@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePlaceholderImages(t *testing.T) {
+	w := &types.Workload{
+		Metadata: map[string]interface{}{"name": "foo"},
+		Containers: map[string]types.Container{
+			"main":    {Image: "."},
+			"sidecar": {Image: "."},
+			"proxy":   {Image: "envoy"},
+		},
+	}
+	require.NoError(t, ResolvePlaceholderImages(w, []string{"example.com/app:v1", "sidecar=example.com/sidecar:v2"}, false))
+	assert.Equal(t, types.WorkloadContainers{
+		"main":    {Image: "example.com/app:v1"},
+		"sidecar": {Image: "example.com/sidecar:v2"},
+		"proxy":   {Image: "envoy"},
+	}, w.Containers)
+}
+
+func TestResolvePlaceholderImages_unresolved(t *testing.T) {
+	w := &types.Workload{
+		Metadata: map[string]interface{}{"name": "foo"},
+		Containers: map[string]types.Container{
+			"main":    {Image: "."},
+			"sidecar": {Image: "."},
+			"proxy":   {Image: "envoy"},
+		},
+	}
+	err := ResolvePlaceholderImages(w, nil, false)
+	assert.EqualError(t, err, "no image was given for the '.' placeholder image of containers: main, sidecar")
+	var unresolved *UnresolvedPlaceholderImagesError
+	require.ErrorAs(t, err, &unresolved)
+	assert.Equal(t, []string{"main", "sidecar"}, unresolved.Containers)
+
+	require.NoError(t, ResolvePlaceholderImages(w, []string{"main=example.com/app:v1"}, true))
+	assert.Equal(t, "example.com/app:v1", w.Containers["main"].Image)
+	assert.Equal(t, ".", w.Containers["sidecar"].Image)
+}
+
+func TestResolvePlaceholderImages_invalid(t *testing.T) {
+	w := &types.Workload{
+		Metadata: map[string]interface{}{"name": "foo"},
+		Containers: map[string]types.Container{
+			"main":    {Image: "."},
+			"sidecar": {Image: "."},
+			"proxy":   {Image: "envoy"},
+		},
+	}
+	for _, tc := range []struct {
+		images []string
+		err    string
+	}{
+		{[]string{"=x"}, "invalid image '=x': expected ref or container=ref"},
+		{[]string{""}, "invalid image '': expected ref or container=ref"},
+		{[]string{"a", "b"}, "only one image without a container name may be specified"},
+		{[]string{"main=a", "main=b"}, "image for container 'main' was specified more than once"},
+		{[]string{"other=a"}, "image for container 'other' does not match any container of the workload"},
+		{[]string{"proxy=a"}, "image for container 'proxy' cannot replace the non-placeholder image 'envoy'"},
+	} {
+		t.Run(tc.err, func(t *testing.T) {
+			assert.EqualError(t, ResolvePlaceholderImages(w, tc.images, false), tc.err)
+		})
+	}
+}
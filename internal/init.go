@@ -2,7 +2,16 @@ package internal
 
 import (
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -33,3 +42,148 @@ func BuildWorkloadComponentForProfile(raw string) (ComponentEntry, error) {
 		return e, nil
 	}
 }
+
+const (
+	PulumiProjectFile   = "Pulumi.yaml"
+	GoModFile           = "go.mod"
+	MainFile            = "main.go"
+	GitIgnoreFile       = ".gitignore"
+	DefaultPulumiModule = "github.com/pulumi/pulumi/sdk/v3"
+	DefaultGoVersion    = "1.25"
+)
+
+var (
+	validProjectNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,100}$`)
+)
+
+type ProjectOptions struct {
+	// Name is the Pulumi project name, it is also used as the Go module path
+	Name string
+	// RuntimeOptions are passed through to the Pulumi go runtime, such as binary or buildTarget
+	RuntimeOptions map[string]string
+}
+
+type pulumiProject struct {
+	Name    string        `yaml:"name"`
+	Runtime pulumiRuntime `yaml:"runtime"`
+}
+
+type pulumiRuntime struct {
+	Name    string            `yaml:"name"`
+	Options map[string]string `yaml:"options,omitempty"`
+}
+
+// DetectProjectName returns the name from the Pulumi project file in the current directory, or the name of the
+// current directory if there is no project file.
+func DetectProjectName() (string, error) {
+	raw, err := os.ReadFile(PulumiProjectFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read project file: %w", err)
+		}
+	} else {
+		// only decode the name since the runtime may be either a plain string or a mapping
+		var p struct {
+			Name string `yaml:"name"`
+		}
+		if err := yaml.Unmarshal(raw, &p); err != nil {
+			return "", fmt.Errorf("failed to decode project file: %w", err)
+		} else if p.Name != "" {
+			return p.Name, nil
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(wd), nil
+}
+
+// ComponentPackages returns the sorted Go packages of the components in the config, including those of the stacks and
+// providers, along with the Pulumi sdk module.
+func ComponentPackages(cfg ScoreConfig) []string {
+	packages := map[string]bool{DefaultPulumiModule: true}
+	if cfg.DefaultWorkloadComponent.Package != "" {
+		packages[cfg.DefaultWorkloadComponent.Package] = true
+	}
+	for _, entry := range cfg.ResourceComponents {
+		if entry.Template == nil {
+			packages[entry.Package] = true
+		}
+	}
+	for _, entry := range cfg.Providers {
+		packages[entry.Package] = true
+	}
+	for _, sc := range cfg.Stacks {
+		if sc.WorkloadComponent != nil {
+			packages[sc.WorkloadComponent.Package] = true
+		}
+		for _, entry := range sc.ResourceComponents {
+			if entry.Template == nil {
+				packages[entry.Package] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(packages))
+}
+
+// GoGetArgs returns the arguments of the 'go get' command that adds the modules of the packages to the go.mod at their
+// latest versions. The go tool resolves each package to the module that contains it.
+func GoGetArgs(packages []string) []string {
+	out := make([]string, 0, len(packages)+1)
+	out = append(out, "get")
+	for _, p := range packages {
+		out = append(out, p+"@latest")
+	}
+	return out
+}
+
+// BuildProjectFiles returns renderers for the files that make up a runnable Pulumi Go project for the config. The
+// go.mod has no requires since component packages are not module paths, run 'go get' with GoGetArgs to add them.
+func BuildProjectFiles(cfg ScoreConfig, opts ProjectOptions) (map[string]func(io.Writer) error, error) {
+	if !validProjectNamePattern.MatchString(opts.Name) {
+		return nil, fmt.Errorf("invalid project name '%s': must match %s", opts.Name, validProjectNamePattern)
+	} else if err := module.CheckImportPath(opts.Name); err != nil {
+		return nil, fmt.Errorf("invalid project name '%s': %w", opts.Name, err)
+	}
+
+	mf := new(modfile.File)
+	if err := mf.AddModuleStmt(opts.Name); err != nil {
+		return nil, err
+	} else if err := mf.AddGoStmt(DefaultGoVersion); err != nil {
+		return nil, err
+	}
+	mf.Cleanup()
+	goMod := modfile.Format(mf.Syntax)
+
+	g, err := cfg.GenerateComponentGraph()
+	if err != nil {
+		return nil, err
+	}
+	stacks, err := cfg.GenerateStackComponentGraphs()
+	if err != nil {
+		return nil, err
+	}
+	mainFile, err := BuildStacksJenFile(g, stacks)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]func(io.Writer) error{
+		PulumiProjectFile: func(w io.Writer) error {
+			return yaml.NewEncoder(w).Encode(pulumiProject{
+				Name:    opts.Name,
+				Runtime: pulumiRuntime{Name: "go", Options: opts.RuntimeOptions},
+			})
+		},
+		GoModFile: func(w io.Writer) error {
+			_, err := w.Write(goMod)
+			return err
+		},
+		MainFile: mainFile.Render,
+		GitIgnoreFile: func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "# the compiled Pulumi program\n/%s\n# temporary files left by interrupted writes\n*.tmp\n", opts.Name)
+			return err
+		},
+	}, nil
+}
@@ -1,8 +1,15 @@
 package internal
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/modfile"
 )
 
 func TestBuildWorkloadComponentForProfile_builtin(t *testing.T) {
@@ -16,3 +23,78 @@ func TestBuildWorkloadComponentForProfile_builtin(t *testing.T) {
 		})
 	}
 }
+
+func renderProjectFiles(t *testing.T, files map[string]func(io.Writer) error) map[string]string {
+	out := make(map[string]string, len(files))
+	for name, render := range files {
+		buff := new(bytes.Buffer)
+		require.NoError(t, render(buff))
+		out[name] = buff.String()
+	}
+	return out
+}
+
+func TestBuildProjectFiles(t *testing.T) {
+	cfg := ScoreConfig{
+		DefaultWorkloadComponent: ComponentEntry{Package: "github.com/astromechza/scorpion/lib/debug", ConstructorFunc: "New", ArgsStruct: "Args"},
+		ResourceComponents: []ResourceComponentEntry{
+			{ComponentEntry: ComponentEntry{Package: "github.com/astromechza/scorpion/lib/random-subdomain", ConstructorFunc: "New", ArgsStruct: "Inputs"}, ResourceType: "dns"},
+			{ComponentEntry: ComponentEntry{Package: "github.com/astromechza/scorpion/lib/debug", ConstructorFunc: "New", ArgsStruct: "Args"}, ResourceType: "debug"},
+		},
+	}
+	files, err := BuildProjectFiles(cfg, ProjectOptions{Name: "example", RuntimeOptions: map[string]string{"binary": "bin/example"}})
+	require.NoError(t, err)
+	rendered := renderProjectFiles(t, files)
+	assert.Equal(t, `name: example
+runtime:
+    name: go
+    options:
+        binary: bin/example
+`, rendered[PulumiProjectFile])
+	mf, err := modfile.Parse(GoModFile, []byte(rendered[GoModFile]), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "example", mf.Module.Mod.Path)
+	assert.Equal(t, DefaultGoVersion, mf.Go.Version)
+	assert.Empty(t, mf.Require)
+	assert.Equal(t, []string{
+		"github.com/astromechza/scorpion/lib/debug",
+		"github.com/astromechza/scorpion/lib/random-subdomain",
+		"github.com/pulumi/pulumi/sdk/v3",
+	}, ComponentPackages(cfg))
+	assert.Equal(t, `package main
+
+import pulumi "github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+func main() {
+	pulumi.Run(func(ctx *pulumi.Context) error {
+		return nil
+	})
+}
+`, rendered[MainFile])
+	assert.Equal(t, "# the compiled Pulumi program\n/example\n# temporary files left by interrupted writes\n*.tmp\n", rendered[GitIgnoreFile])
+}
+
+func TestGoGetArgs(t *testing.T) {
+	assert.Equal(t, []string{"get", "github.com/pulumi/pulumi-aws/sdk/v6/go/aws/rds@latest", "github.com/pulumi/pulumi/sdk/v3@latest"}, GoGetArgs([]string{
+		"github.com/pulumi/pulumi-aws/sdk/v6/go/aws/rds",
+		"github.com/pulumi/pulumi/sdk/v3",
+	}))
+}
+
+func TestBuildProjectFiles_invalid_name(t *testing.T) {
+	_, err := BuildProjectFiles(ScoreConfig{}, ProjectOptions{Name: "my project"})
+	assert.EqualError(t, err, "invalid project name 'my project': must match ^[A-Za-z0-9_.-]{1,100}$")
+}
+
+func TestDetectProjectName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my-dir")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	t.Chdir(dir)
+	n, err := DetectProjectName()
+	require.NoError(t, err)
+	assert.Equal(t, "my-dir", n)
+	require.NoError(t, os.WriteFile(PulumiProjectFile, []byte("name: other\nruntime: yaml\n"), 0o644))
+	n, err = DetectProjectName()
+	require.NoError(t, err)
+	assert.Equal(t, "other", n)
+}
@@ -0,0 +1,369 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/types"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/score-spec/score-go/framework"
+	"golang.org/x/tools/go/packages"
+)
+
+var (
+	singlePlaceholderPattern = regexp.MustCompile(`^\$\{resources\.[^}]+}$`)
+)
+
+// LoadArgsTypes loads the component packages from the Go module in dir and records the type of each args struct, and
+// of the outputs struct returned by each constructor, on the graph nodes so that BuildJenFile can generate values of
+// the exact field types. Components whose package cannot be loaded, such as before 'go mod tidy' has resolved it, keep
+// generating untyped values and the load errors are returned as warnings.
+func (g *ComponentGraph) LoadArgsTypes(dir string) ([]error, error) {
+	pkgPaths := make(map[string]bool)
+	for _, n := range g.Nodes {
+		if n.hasConstructor() {
+			pkgPaths[n.Package] = true
+		}
+	}
+	if len(pkgPaths) == 0 {
+		return nil, nil
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes,
+		Dir:  dir,
+	}, slices.Sorted(maps.Keys(pkgPaths))...)
+	if err != nil {
+		return []error{fmt.Errorf("failed to load component packages: %w", err)}, nil
+	}
+	byPath := make(map[string]*types.Package, len(pkgs))
+	loadErrs := make(map[string]error)
+	for _, p := range pkgs {
+		if len(p.Errors) > 0 {
+			loadErrs[p.PkgPath] = p.Errors[0]
+			continue
+		}
+		byPath[p.PkgPath] = p.Types
+	}
+	warnings := make([]error, 0)
+	for _, pkgPath := range slices.Sorted(maps.Keys(pkgPaths)) {
+		if _, ok := byPath[pkgPath]; !ok {
+			warnings = append(warnings, fmt.Errorf("failed to load component package '%s': %v", pkgPath, cmp.Or(loadErrs[pkgPath], errors.New("package was not found"))))
+		}
+	}
+	for id, n := range g.Nodes {
+		pkg, ok := byPath[n.Package]
+		if !n.hasConstructor() || !ok {
+			continue
+		}
+		if n.argsTypeInfo, err = lookupArgsType(pkg, n.Package, n.ArgsType); err != nil {
+			return warnings, fmt.Errorf("component %s: %w", n.Name, err)
+		}
+		n.outputsTypeInfo = lookupOutputsType(pkg, n.Constructor)
+		g.Nodes[id] = n
+	}
+	return warnings, nil
+}
+
+// lookupOutputsType returns the named struct type that the constructor in the package returns a pointer to, or nil
+// if the constructor does not have that shape.
+func lookupOutputsType(pkg *types.Package, constructor string) *types.Named {
+	fn, ok := pkg.Scope().Lookup(constructor).(*types.Func)
+	if !ok {
+		return nil
+	}
+	results := fn.Type().(*types.Signature).Results()
+	if results.Len() == 0 {
+		return nil
+	}
+	result := results.At(0).Type()
+	if p, ok := result.(*types.Pointer); ok {
+		result = p.Elem()
+	}
+	if named, ok := result.(*types.Named); ok {
+		if _, ok := named.Underlying().(*types.Struct); ok {
+			return named
+		}
+	}
+	return nil
+}
+
+// buildOutputTypeFunc returns a function that returns the type of the output field that a
+// ${resources.<alias>.<field>} reference refers to, or nil if the outputs struct of the dependency is not known.
+func buildOutputTypeFunc(g ComponentGraph, dependencies map[LocalAlias]ComponentGoIdentifier) func(ref string) types.Type {
+	return func(ref string) types.Type {
+		parts := framework.SplitRefParts(ref)
+		if len(parts) != 3 || parts[0] != "resources" {
+			return nil
+		}
+		dep, ok := dependencies[LocalAlias(parts[1])]
+		if !ok || g.Nodes[dep].outputsTypeInfo == nil {
+			return nil
+		}
+		if f, ok := lookupStructField(g.Nodes[dep].outputsTypeInfo.Underlying().(*types.Struct), parts[2]); ok {
+			return f.Type()
+		}
+		return nil
+	}
+}
+
+// acceptsStringOutput returns true if the type accepts the pulumi.StringOutput that pulumi.Sprintf returns.
+func acceptsStringOutput(target types.Type) bool {
+	if iface, ok := target.Underlying().(*types.Interface); ok && iface.Empty() {
+		return true
+	}
+	named, ok := target.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == DefaultPulumiPackage &&
+		slices.Contains([]string{"Input", "StringInput", "StringPtrInput"}, named.Obj().Name())
+}
+
+// packageQualifiedType returns the type as it is written in Go code, such as pulumi.IntOutput.
+func packageQualifiedType(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		return p.Name()
+	})
+}
+
+// lookupArgsType returns the named struct type of the args struct in the package.
+func lookupArgsType(pkg *types.Package, pkgPath, argsType string) (*types.Named, error) {
+	if pkg == nil {
+		return nil, fmt.Errorf("package '%s' was not loaded", pkgPath)
+	}
+	tn, ok := pkg.Scope().Lookup(argsType).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("package '%s' has no type '%s'", pkgPath, argsType)
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("type '%s' in package '%s' is not a named type", argsType, pkgPath)
+	} else if _, ok := named.Underlying().(*types.Struct); !ok {
+		return nil, fmt.Errorf("type '%s' in package '%s' is not a struct", argsType, pkgPath)
+	}
+	return named, nil
+}
+
+// qualifiedType returns the jen code that refers to the named type.
+func qualifiedType(named *types.Named) *jen.Statement {
+	return jen.Qual(named.Obj().Pkg().Path(), named.Obj().Name())
+}
+
+// lookupStructField finds the field of the struct matching a param either by its pulumi tag or by its Go name.
+func lookupStructField(s *types.Struct, param string) (*types.Var, bool) {
+	goName := toParamName(param).GoString()
+	for i := 0; i < s.NumFields(); i++ {
+		if f := s.Field(i); f.Exported() && reflect.StructTag(s.Tag(i)).Get("pulumi") == param {
+			return f, true
+		}
+	}
+	for i := 0; i < s.NumFields(); i++ {
+		if f := s.Field(i); f.Exported() && f.Name() == goName {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// pulumifyStructFields converts the params into a dict of field assignments for the struct. Params without a matching
+// field are all reported together.
+func pulumifyStructFields(path []string, params map[string]interface{}, named *types.Named, innerSubstFunc func(fmtArgs *[]jen.Code) func(s string) (string, error), outputType func(ref string) types.Type) (jen.Dict, error) {
+	s := named.Underlying().(*types.Struct)
+	out := make(jen.Dict, len(params))
+	errs := make([]error, 0)
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		f, ok := lookupStructField(s, k)
+		if !ok {
+			errs = append(errs, fmt.Errorf("param '%s' does not match any field of %s", strings.Join(append(path, k), "."), named.Obj().Name()))
+			continue
+		}
+		o, err := pulumifyTypedValue(append(path, k), params[k], f.Type(), innerSubstFunc, outputType)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		out[jen.Id(f.Name())] = o
+	}
+	return out, errors.Join(errs...)
+}
+
+// pulumifyTypedValue converts a param value into Go code that constructs a value assignable to the target type.
+func pulumifyTypedValue(path []string, raw interface{}, target types.Type, innerSubstFunc func(fmtArgs *[]jen.Code) func(s string) (string, error), outputType func(ref string) types.Type) (jen.Code, error) {
+	if raw == nil {
+		return jen.Nil(), nil
+	}
+	if sv, ok := raw.(secretValue); ok {
+		secretType, convert, err := typedSecretOutputType(path, target)
+		if err != nil {
+			return nil, err
+		}
+		inner, err := pulumifyTypedValue(path, sv.value, target, innerSubstFunc, outputType)
+		if err != nil || sv.value == nil {
+			return inner, err
+		}
+		if convert != "" {
+			return wrapSecret(inner, secretType).Dot(convert).Call(), nil
+		}
+		return wrapSecret(inner, secretType), nil
+	}
+	if o, ok := raw.(outputRef); ok {
+		raw = string(o)
+	}
+	// a lone resource placeholder passes the referenced output through unchanged so that it keeps its own type, as long
+	// as that type is assignable to the field. Otherwise fields that accept a string format the output instead.
+	if s, ok := raw.(string); ok && singlePlaceholderPattern.MatchString(s) {
+		if _, isBasic := target.Underlying().(*types.Basic); !isBasic {
+			ref := s[2 : len(s)-1]
+			refType := outputType(ref)
+			if (refType != nil && types.AssignableTo(refType, target)) || (refType == nil && !acceptsStringOutput(target)) {
+				fmtArgs := make([]jen.Code, 0, 1)
+				if _, err := innerSubstFunc(&fmtArgs)(ref); err != nil {
+					return nil, fmt.Errorf("failed to substitute %q at %s: %w", s, strings.Join(path, "."), err)
+				}
+				return fmtArgs[0], nil
+			} else if !acceptsStringOutput(target) {
+				return nil, fmt.Errorf("cannot assign %s of type %s to %s at %s", ref, packageQualifiedType(refType), packageQualifiedType(target), strings.Join(path, "."))
+			}
+		}
+	}
+
+	switch t := target.(type) {
+	case *types.Pointer:
+		if named, ok := t.Elem().(*types.Named); ok {
+			if _, ok := named.Underlying().(*types.Struct); ok {
+				c, err := pulumifyTypedValue(path, raw, named, innerSubstFunc, outputType)
+				if err != nil {
+					return nil, err
+				}
+				return jen.Op("&").Add(c), nil
+			}
+		}
+	case *types.Named:
+		switch u := t.Underlying().(type) {
+		case *types.Interface:
+			return pulumifyInterfaceValue(path, raw, t, u, innerSubstFunc, outputType)
+		case *types.Struct:
+			m, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected a map for %s at %s but got %T", t.Obj().Name(), strings.Join(path, "."), raw)
+			}
+			d, err := pulumifyStructFields(path, m, t, innerSubstFunc, outputType)
+			if err != nil {
+				return nil, err
+			}
+			return qualifiedType(t).Values(d), nil
+		case *types.Slice:
+			l, ok := raw.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected a list for %s at %s but got %T", t.Obj().Name(), strings.Join(path, "."), raw)
+			}
+			listValues := make([]jen.Code, 0, len(l))
+			for i, v := range l {
+				out, err := pulumifyTypedValue(append(path, fmt.Sprintf("[%d]", i)), v, u.Elem(), innerSubstFunc, outputType)
+				if err != nil {
+					return nil, err
+				}
+				listValues = append(listValues, out)
+			}
+			return qualifiedType(t).Values(jen.List(listValues...)), nil
+		case *types.Map:
+			m, ok := raw.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected a map for %s at %s but got %T", t.Obj().Name(), strings.Join(path, "."), raw)
+			}
+			mapValues := make(jen.Dict, len(m))
+			for k, v := range m {
+				out, err := pulumifyTypedValue(append(path, k), v, u.Elem(), innerSubstFunc, outputType)
+				if err != nil {
+					return nil, err
+				}
+				mapValues[jen.Lit(k)] = out
+			}
+			return qualifiedType(t).Values(mapValues), nil
+		case *types.Basic:
+			lit, err := basicLiteral(path, raw, u)
+			if err != nil {
+				return nil, err
+			}
+			return qualifiedType(t).Call(lit), nil
+		}
+	case *types.Basic:
+		return basicLiteral(path, raw, t)
+	case *types.Interface:
+		if t.Empty() {
+			return pulumifyValue(path, raw, innerSubstFunc)
+		}
+	}
+	return nil, fmt.Errorf("unsupported field type %s at %s", target, strings.Join(path, "."))
+}
+
+// pulumifyInterfaceValue converts a param value for an input interface such as pulumi.StringArrayInput by finding the
+// concrete type that Pulumi SDKs declare alongside it, such as pulumi.StringArray or FooArgs for FooInput.
+func pulumifyInterfaceValue(path []string, raw interface{}, named *types.Named, iface *types.Interface, innerSubstFunc func(fmtArgs *[]jen.Code) func(s string) (string, error), outputType func(ref string) types.Type) (jen.Code, error) {
+	base, ok := strings.CutSuffix(named.Obj().Name(), "Input")
+	if !ok || base == "" {
+		// generic inputs such as pulumi.Input accept any of the untyped wrappers
+		return pulumifyValue(path, raw, innerSubstFunc)
+	}
+	candidates := []string{base, base + "Args"}
+	if b, ok := strings.CutSuffix(base, "Ptr"); ok {
+		candidates = []string{b + "Args", b}
+	}
+	for _, c := range candidates {
+		tn, ok := named.Obj().Pkg().Scope().Lookup(c).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		concrete := tn.Type()
+		if types.Implements(concrete, iface) {
+			if b, ok := concrete.Underlying().(*types.Basic); ok && b.Info()&types.IsString != 0 {
+				if s, ok := raw.(string); ok && strings.Contains(s, "${") {
+					// Sprintf returns a StringOutput which satisfies the string inputs
+					return pulumifyValue(path, raw, innerSubstFunc)
+				}
+			}
+			return pulumifyTypedValue(path, raw, concrete, innerSubstFunc, outputType)
+		} else if types.Implements(types.NewPointer(concrete), iface) {
+			return pulumifyTypedValue(path, raw, types.NewPointer(concrete), innerSubstFunc, outputType)
+		}
+	}
+	return nil, fmt.Errorf("no concrete type implementing %s found for %s", named.Obj().Name(), strings.Join(path, "."))
+}
+
+// basicLiteral converts a param value into a literal of the basic type.
+func basicLiteral(path []string, raw interface{}, basic *types.Basic) (jen.Code, error) {
+	switch info := basic.Info(); {
+	case info&types.IsString != 0:
+		if s, ok := raw.(string); ok {
+			if strings.Contains(s, "${") {
+				return nil, fmt.Errorf("cannot substitute %q at %s into a plain %s", s, strings.Join(path, "."), basic.Name())
+			}
+			return jen.Lit(s), nil
+		}
+	case info&types.IsBoolean != 0:
+		if b, ok := raw.(bool); ok {
+			return jen.Lit(b), nil
+		}
+	case info&types.IsInteger != 0:
+		switch n := raw.(type) {
+		case int:
+			return jen.Lit(n), nil
+		case float64:
+			if n == math.Trunc(n) {
+				return jen.Lit(int(n)), nil
+			}
+		}
+	case info&types.IsFloat != 0:
+		switch n := raw.(type) {
+		case int:
+			return jen.Lit(float64(n)), nil
+		case float64:
+			return jen.Lit(n), nil
+		}
+	}
+	return nil, fmt.Errorf("expected a value of type %s at %s but got %T", basic.Name(), strings.Join(path, "."), raw)
+}
@@ -0,0 +1,251 @@
+package internal
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakePulumiSource = `package pulumi
+
+type Input interface{ ElementType() }
+
+type StringOutput struct{}
+func (StringOutput) ElementType() {}
+func (StringOutput) ToStringOutput() StringOutput { return StringOutput{} }
+
+type StringInput interface {
+	ElementType()
+	ToStringOutput() StringOutput
+}
+type String string
+func (String) ElementType() {}
+func (String) ToStringOutput() StringOutput { return StringOutput{} }
+func (String) ToStringPtrOutput() {}
+
+type StringPtrInput interface{ ToStringPtrOutput() }
+
+type IntOutput struct{}
+func (IntOutput) ElementType() {}
+func (IntOutput) ToIntOutput() {}
+
+type IntInput interface{ ToIntOutput() }
+type Int int
+func (Int) ToIntOutput() {}
+
+type StringArrayInput interface{ ToStringArrayOutput() }
+type StringArray []StringInput
+func (StringArray) ToStringArrayOutput() {}
+
+type StringMapInput interface{ ToStringMapOutput() }
+type StringMap map[string]StringInput
+func (StringMap) ToStringMapOutput() {}
+
+type MapInput interface{ ToMapOutput() }
+type Map map[string]Input
+func (Map) ToMapOutput() {}
+`
+
+const fakeComponentSource = `package comp
+
+import "github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+
+type NestedPtrInput interface{ ToNestedPtrOutput() }
+type NestedArgs struct {
+	Size pulumi.IntInput ` + "`pulumi:\"size\"`" + `
+}
+func (NestedArgs) ToNestedPtrOutput() {}
+
+type Args struct {
+	Name   pulumi.StringInput      ` + "`pulumi:\"name\"`" + `
+	Count  pulumi.IntInput         ` + "`pulumi:\"count\"`" + `
+	Tags   pulumi.StringArrayInput ` + "`pulumi:\"tags\"`" + `
+	Labels pulumi.StringMapInput   ` + "`pulumi:\"labels\"`" + `
+	Nested NestedPtrInput          ` + "`pulumi:\"nested\"`" + `
+	Secret pulumi.StringPtrInput   ` + "`pulumi:\"secret\"`" + `
+	Values pulumi.MapInput         ` + "`pulumi:\"values\"`" + `
+	Plain  string
+}
+
+type Outputs struct {
+	Name pulumi.StringOutput ` + "`pulumi:\"name\"`" + `
+	Port pulumi.IntOutput    ` + "`pulumi:\"port\"`" + `
+}
+
+func New(ctx interface{}, name string, args *Args) (*Outputs, error) { return nil, nil }
+`
+
+type fakeImporter map[string]*types.Package
+
+func (f fakeImporter) Import(path string) (*types.Package, error) {
+	if p, ok := f[path]; ok {
+		return p, nil
+	}
+	return importer.Default().Import(path)
+}
+
+func typeCheckFakePackage(t *testing.T, imp fakeImporter, path, src string) *types.Package {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path+".go", src, 0)
+	require.NoError(t, err)
+	p, err := (&types.Config{Importer: imp}).Check(path, fset, []*ast.File{f}, nil)
+	require.NoError(t, err)
+	imp[path] = p
+	return p
+}
+
+func fakeComponentTypes(t *testing.T) (*types.Named, *types.Named) {
+	imp := make(fakeImporter)
+	typeCheckFakePackage(t, imp, "github.com/pulumi/pulumi/sdk/v3/go/pulumi", fakePulumiSource)
+	p := typeCheckFakePackage(t, imp, "example.com/comp", fakeComponentSource)
+	named, err := lookupArgsType(p, "example.com/comp", "Args")
+	require.NoError(t, err)
+	return named, lookupOutputsType(p, "New")
+}
+
+func Test_lookupArgsType(t *testing.T) {
+	imp := make(fakeImporter)
+	typeCheckFakePackage(t, imp, "github.com/pulumi/pulumi/sdk/v3/go/pulumi", fakePulumiSource)
+	p := typeCheckFakePackage(t, imp, "example.com/comp", fakeComponentSource)
+	_, err := lookupArgsType(p, "example.com/comp", "Missing")
+	assert.EqualError(t, err, "package 'example.com/comp' has no type 'Missing'")
+	_, err = lookupArgsType(p, "example.com/comp", "NestedPtrInput")
+	assert.EqualError(t, err, "type 'NestedPtrInput' in package 'example.com/comp' is not a struct")
+	_, err = lookupArgsType(nil, "example.com/other", "Args")
+	assert.EqualError(t, err, "package 'example.com/other' was not loaded")
+}
+
+func Test_lookupOutputsType(t *testing.T) {
+	imp := make(fakeImporter)
+	typeCheckFakePackage(t, imp, "github.com/pulumi/pulumi/sdk/v3/go/pulumi", fakePulumiSource)
+	p := typeCheckFakePackage(t, imp, "example.com/comp", fakeComponentSource)
+	if o := lookupOutputsType(p, "New"); assert.NotNil(t, o) {
+		assert.Equal(t, "Outputs", o.Obj().Name())
+	}
+	assert.Nil(t, lookupOutputsType(p, "Missing"))
+	assert.Nil(t, lookupOutputsType(p, "Args"))
+}
+
+func TestBuildJenFile_typed(t *testing.T) {
+	argsType, outputsType := fakeComponentTypes(t)
+	f, err := BuildJenFile(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedDbf876cb74": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "shared.db", argsTypeInfo: argsType, outputsTypeInfo: outputsType},
+			"workloadFoo6d39e786": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "workload.foo", argsTypeInfo: argsType, Params: map[string]interface{}{
+				"name":   "${resources.db.name}",
+				"count":  float64(3),
+				"tags":   []interface{}{"a", "b-${resources.db.name}"},
+				"labels": map[string]interface{}{"x": "y"},
+				"nested": map[string]interface{}{"size": 10},
+				"values": map[string]interface{}{"a": 1},
+				"plain":  "hello",
+			}},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"workloadFoo6d39e786": {"db": "sharedDbf876cb74"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `workloadFoo6d39e786, err := comp.New(ctx, "workload.foo", &comp.Args{
+			Count:  pulumi.Int(3),
+			Labels: pulumi.StringMap{"x": pulumi.String("y")},
+			Name:   sharedDbf876cb74.Name,
+			Nested: comp.NestedArgs{Size: pulumi.Int(10)},
+			Plain:  "hello",
+			Tags:   pulumi.StringArray{pulumi.String("a"), pulumi.Sprintf("b-%v", sharedDbf876cb74.Name)},
+			Values: pulumi.Map{"a": pulumi.Int(1)},
+		})`)
+}
+
+func TestBuildJenFile_typed_mismatches(t *testing.T) {
+	argsType, _ := fakeComponentTypes(t)
+	_, err := BuildJenFile(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedDbf876cb74": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "shared.db", argsTypeInfo: argsType, Params: map[string]interface{}{
+				"unknown": "x",
+				"count":   "three",
+			}},
+			"workloadFoo6d39e786": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "workload.foo", argsTypeInfo: argsType, Params: map[string]interface{}{
+				"nested": map[string]interface{}{"colour": "blue"},
+			}},
+		},
+	})
+	require.Error(t, err)
+	assert.Equal(t, `shared.db: expected a value of type int at count but got string
+param 'unknown' does not match any field of Args
+workload.foo: param 'nested.colour' does not match any field of NestedArgs`, err.Error())
+}
+
+func TestBuildJenFile_typed_output_types(t *testing.T) {
+	argsType, outputsType := fakeComponentTypes(t)
+	graph := func(params map[string]interface{}) ComponentGraph {
+		return ComponentGraph{
+			Nodes: map[ComponentGoIdentifier]ComponentInstance{
+				"sharedDbf876cb74":    {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "shared.db", argsTypeInfo: argsType, outputsTypeInfo: outputsType},
+				"workloadFoo6d39e786": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "workload.foo", argsTypeInfo: argsType, Params: params},
+			},
+			Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+				"workloadFoo6d39e786": {"db": "sharedDbf876cb74"},
+			},
+		}
+	}
+
+	t.Run("int output to string input", func(t *testing.T) {
+		f, err := BuildJenFile(graph(map[string]interface{}{"name": "${resources.db.port}"}))
+		require.NoError(t, err)
+		assert.Contains(t, f.GoString(), `&comp.Args{Name: pulumi.Sprintf("%v", sharedDbf876cb74.Port)}`)
+	})
+
+	t.Run("int output to int input", func(t *testing.T) {
+		f, err := BuildJenFile(graph(map[string]interface{}{"count": "${resources.db.port}"}))
+		require.NoError(t, err)
+		assert.Contains(t, f.GoString(), `&comp.Args{Count: sharedDbf876cb74.Port}`)
+	})
+
+	t.Run("string output to int input", func(t *testing.T) {
+		_, err := BuildJenFile(graph(map[string]interface{}{"count": "${resources.db.name}"}))
+		assert.EqualError(t, err, "workload.foo: cannot assign resources.db.name of type pulumi.StringOutput to pulumi.IntInput at count")
+	})
+
+	t.Run("unknown output to int input", func(t *testing.T) {
+		f, err := BuildJenFile(graph(map[string]interface{}{"count": "${resources.db.other}"}))
+		require.NoError(t, err)
+		assert.Contains(t, f.GoString(), `&comp.Args{Count: sharedDbf876cb74.Other}`)
+	})
+}
+
+func TestLoadArgsTypes_unloadable_package(t *testing.T) {
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n\ngo 1.21\n"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "comp"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "comp", "comp.go"), []byte(`package comp
+
+type Args struct{ Name *string }
+type Outputs struct{ Name string }
+
+func New(ctx interface{}, name string, args *Args) (*Outputs, error) { return nil, nil }
+`), 0o644))
+
+	g := ComponentGraph{Nodes: map[ComponentGoIdentifier]ComponentInstance{
+		"sharedDbf876cb74":    {Package: "example.com/app/comp", Constructor: "New", ArgsType: "Args", Name: "shared.db"},
+		"workloadFoo6d39e786": {Package: "example.com/missing", Constructor: "New", ArgsType: "Args", Name: "workload.foo"},
+	}}
+	warnings, err := g.LoadArgsTypes(dir)
+	require.NoError(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0].Error(), "failed to load component package 'example.com/missing': ")
+	}
+	assert.NotNil(t, g.Nodes["sharedDbf876cb74"].argsTypeInfo)
+	assert.NotNil(t, g.Nodes["sharedDbf876cb74"].outputsTypeInfo)
+	assert.Nil(t, g.Nodes["workloadFoo6d39e786"].argsTypeInfo)
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+)
+
+type WorkloadListing struct {
+	Name       string
+	Identifier ComponentGoIdentifier
+	Component  ComponentEntry
+	Resources  []ResourceListing
+}
+
+type ResourceListing struct {
+	Alias      string
+	Type       string
+	Class      string
+	Id         string
+	Identifier ComponentGoIdentifier
+	// Component is the first matching entry in the component library, or nil if no entry matched
+	Component *ResourceComponentEntry
+}
+
+// ListWorkloads describes each workload in the config along with its resources and the component library entries
+// that would be used to provision them. Resources are sorted by alias.
+func (cfg *ScoreConfig) ListWorkloads() []WorkloadListing {
+	out := make([]WorkloadListing, 0, len(cfg.Workloads))
+	for _, workload := range cfg.Workloads {
+		workloadName := workload.Metadata["name"].(string)
+		wl := WorkloadListing{
+			Name:       workloadName,
+			Identifier: GenerateGoVar("workload." + workloadName),
+			Component:  cfg.DefaultWorkloadComponent,
+			Resources:  make([]ResourceListing, 0, len(workload.Resources)),
+		}
+		for _, alias := range slices.Sorted(maps.Keys(workload.Resources)) {
+			res := workload.Resources[alias]
+			resId, resClass := resolveResourceIdAndClass(workloadName, alias, res)
+			rl := ResourceListing{
+				Alias:      alias,
+				Type:       res.Type,
+				Class:      resClass,
+				Id:         resId,
+				Identifier: GenerateGoVar(resId),
+			}
+			if entry, ok := FindResourceComponent(cfg.ResourceComponents, res.Type, resClass, resId); ok {
+				rl.Component = &entry
+			}
+			wl.Resources = append(wl.Resources, rl)
+		}
+		out = append(out, wl)
+	}
+	return out
+}
+
+// WriteWorkloadListing writes the listing as an aligned table with one row per workload followed by a row per resource.
+func WriteWorkloadListing(w io.Writer, listing []WorkloadListing) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "WORKLOAD\tRESOURCE\tTYPE\tCLASS\tID\tIDENTIFIER\tCOMPONENT")
+	for _, wl := range listing {
+		_, _ = fmt.Fprintf(tw, "%s\t-\t-\t-\tworkload.%s\t%s\t%s\n", wl.Name, wl.Name, wl.Identifier, wl.Component)
+		for _, rl := range wl.Resources {
+			component := "<no matching component>"
+			if rl.Component != nil {
+				component = rl.Component.String()
+			} else if rl.Type == EnvironmentResourceType {
+				component = "<built-in environment>"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", wl.Name, rl.Alias, rl.Type, rl.Class, rl.Id, rl.Identifier, component)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListWorkloads(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"b": {Type: "thing", Id: ref("thing"), Class: ref("large")},
+					"a": {Type: "other"},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "github.com/example/workload", ConstructorFunc: "New", ArgsStruct: "Args"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry:     ComponentEntry{Package: "github.com/example/thing", ConstructorFunc: "New", ArgsStruct: "Args"},
+				ResourceType:       "thing",
+				ResourceClassRegex: `.*`,
+				ResourceIdRegex:    `.*`,
+			},
+		},
+	}
+	listing := cfg.ListWorkloads()
+	assert.Equal(t, []WorkloadListing{{
+		Name:       "foo",
+		Identifier: "workloadFoo6d39e786",
+		Component:  cfg.DefaultWorkloadComponent,
+		Resources: []ResourceListing{
+			{Alias: "a", Type: "other", Class: "default", Id: "workload.foo.a", Identifier: "workloadFooAc5757e5b"},
+			{Alias: "b", Type: "thing", Class: "large", Id: "shared.thing", Identifier: "sharedThingdae392ce", Component: &cfg.ResourceComponents[0]},
+		},
+	}}, listing)
+
+	buff := new(bytes.Buffer)
+	require.NoError(t, WriteWorkloadListing(buff, listing))
+	assert.Equal(t, `WORKLOAD  RESOURCE  TYPE   CLASS    ID              IDENTIFIER            COMPONENT
+foo       -         -      -        workload.foo    workloadFoo6d39e786   github.com/example/workload.New(Args)
+foo       a         other  default  workload.foo.a  workloadFooAc5757e5b  <no matching component>
+foo       b         thing  large    shared.thing    sharedThingdae392ce   github.com/example/thing.New(Args)
+`, buff.String())
+}
@@ -0,0 +1,202 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dave/jennifer/jen"
+	"gopkg.in/yaml.v3"
+)
+
+// OptionsAnnotation is the annotation on a workload, or on one of its resources, that adds resource options to the
+// component provisioning it. The value is a yaml or json options block.
+const OptionsAnnotation = "scorpion.dev/options"
+
+// ResourceOptions are the Pulumi resource options passed to a component constructor.
+type ResourceOptions struct {
+	Protect             *bool           `yaml:"protect,omitempty"`
+	RetainOnDelete      *bool           `yaml:"retain_on_delete,omitempty"`
+	DeleteBeforeReplace *bool           `yaml:"delete_before_replace,omitempty"`
+	IgnoreChanges       []string        `yaml:"ignore_changes,omitempty"`
+	ReplaceOnChanges    []string        `yaml:"replace_on_changes,omitempty"`
+	CustomTimeouts      *CustomTimeouts `yaml:"custom_timeouts,omitempty"`
+}
+
+// CustomTimeouts are the create, update, and delete timeouts of a resource as Go durations such as 10m.
+type CustomTimeouts struct {
+	Create string `yaml:"create,omitempty"`
+	Update string `yaml:"update,omitempty"`
+	Delete string `yaml:"delete,omitempty"`
+}
+
+// Validate returns an error if any of the custom timeouts is not a valid duration.
+func (o ResourceOptions) Validate() error {
+	if o.CustomTimeouts != nil {
+		for _, timeout := range []struct{ name, value string }{
+			{"create", o.CustomTimeouts.Create},
+			{"update", o.CustomTimeouts.Update},
+			{"delete", o.CustomTimeouts.Delete},
+		} {
+			if _, err := time.ParseDuration(timeout.value); timeout.value != "" && err != nil {
+				return fmt.Errorf("invalid %s timeout '%s': %w", timeout.name, timeout.value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge returns the options with the other options applied over them. Flags and timeouts set in the other options
+// take precedence while property lists are combined.
+func (o ResourceOptions) Merge(other ResourceOptions) ResourceOptions {
+	out := o
+	if other.Protect != nil {
+		out.Protect = other.Protect
+	}
+	if other.RetainOnDelete != nil {
+		out.RetainOnDelete = other.RetainOnDelete
+	}
+	if other.DeleteBeforeReplace != nil {
+		out.DeleteBeforeReplace = other.DeleteBeforeReplace
+	}
+	out.IgnoreChanges = mergeUnique(o.IgnoreChanges, other.IgnoreChanges)
+	out.ReplaceOnChanges = mergeUnique(o.ReplaceOnChanges, other.ReplaceOnChanges)
+	if other.CustomTimeouts != nil {
+		timeouts := CustomTimeouts{}
+		if o.CustomTimeouts != nil {
+			timeouts = *o.CustomTimeouts
+		}
+		if other.CustomTimeouts.Create != "" {
+			timeouts.Create = other.CustomTimeouts.Create
+		}
+		if other.CustomTimeouts.Update != "" {
+			timeouts.Update = other.CustomTimeouts.Update
+		}
+		if other.CustomTimeouts.Delete != "" {
+			timeouts.Delete = other.CustomTimeouts.Delete
+		}
+		out.CustomTimeouts = &timeouts
+	}
+	return out
+}
+
+// mergeUnique appends the items of b that are not already in a.
+func mergeUnique(a, b []string) []string {
+	out := slices.Clone(a)
+	for _, v := range b {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseOptionsAnnotation decodes the options annotation from the annotations in the metadata of a workload or
+// resource. It returns empty options if the annotation is not set.
+func parseOptionsAnnotation(metadata map[string]interface{}) (ResourceOptions, error) {
+	var out ResourceOptions
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	raw, ok := annotations[OptionsAnnotation]
+	if !ok {
+		return out, nil
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return out, fmt.Errorf("annotation %s must be a string", OptionsAnnotation)
+	}
+	d := yaml.NewDecoder(strings.NewReader(s))
+	d.KnownFields(true)
+	if err := d.Decode(&out); err != nil && !errors.Is(err, io.EOF) {
+		return out, fmt.Errorf("annotation %s: %w", OptionsAnnotation, err)
+	} else if err := out.Validate(); err != nil {
+		return out, fmt.Errorf("annotation %s: %w", OptionsAnnotation, err)
+	}
+	return out, nil
+}
+
+// buildResourceOptions returns the pulumi.ResourceOption arguments for the options, provider, and aliases of the
+// component in a stable order.
+func buildResourceOptions(n ComponentInstance) []jen.Code {
+	o := n.Options
+	out := make([]jen.Code, 0)
+	if n.Provider != "" {
+		out = append(out, jen.Qual(DefaultPulumiPackage, "Provider").Call(jen.Id(string(n.Provider))))
+	}
+	if len(n.Aliases) > 0 {
+		out = append(out, buildAliasesOption(n.Aliases))
+	}
+	if o.Protect != nil {
+		out = append(out, jen.Qual(DefaultPulumiPackage, "Protect").Call(jen.Lit(*o.Protect)))
+	}
+	if o.RetainOnDelete != nil {
+		out = append(out, jen.Qual(DefaultPulumiPackage, "RetainOnDelete").Call(jen.Lit(*o.RetainOnDelete)))
+	}
+	if o.DeleteBeforeReplace != nil {
+		out = append(out, jen.Qual(DefaultPulumiPackage, "DeleteBeforeReplace").Call(jen.Lit(*o.DeleteBeforeReplace)))
+	}
+	if len(o.IgnoreChanges) > 0 {
+		out = append(out, jen.Qual(DefaultPulumiPackage, "IgnoreChanges").Call(stringSliceLit(o.IgnoreChanges)))
+	}
+	if len(o.ReplaceOnChanges) > 0 {
+		out = append(out, jen.Qual(DefaultPulumiPackage, "ReplaceOnChanges").Call(stringSliceLit(o.ReplaceOnChanges)))
+	}
+	if o.CustomTimeouts != nil {
+		timeouts := jen.Dict{}
+		if o.CustomTimeouts.Create != "" {
+			timeouts[jen.Id("Create")] = jen.Lit(o.CustomTimeouts.Create)
+		}
+		if o.CustomTimeouts.Update != "" {
+			timeouts[jen.Id("Update")] = jen.Lit(o.CustomTimeouts.Update)
+		}
+		if o.CustomTimeouts.Delete != "" {
+			timeouts[jen.Id("Delete")] = jen.Lit(o.CustomTimeouts.Delete)
+		}
+		out = append(out, jen.Qual(DefaultPulumiPackage, "Timeouts").Call(jen.Op("&").Qual(DefaultPulumiPackage, "CustomTimeouts").Values(timeouts)))
+	}
+	return out
+}
+
+func stringSliceLit(items []string) *jen.Statement {
+	values := make([]jen.Code, 0, len(items))
+	for _, v := range items {
+		values = append(values, jen.Lit(v))
+	}
+	return jen.Index().String().Values(values...)
+}
+
+// yamlResourceOptions returns the options block of the component in the Pulumi yaml format, or nil if there are none.
+func yamlResourceOptions(n ComponentInstance) map[string]interface{} {
+	o := n.Options
+	out := make(map[string]interface{})
+	if n.Provider != "" {
+		out["provider"] = "${" + string(n.Provider) + "}"
+	}
+	if len(n.Aliases) > 0 {
+		out["aliases"] = yamlAliases(n.YamlType, n.Aliases)
+	}
+	if o.Protect != nil {
+		out["protect"] = *o.Protect
+	}
+	if o.RetainOnDelete != nil {
+		out["retainOnDelete"] = *o.RetainOnDelete
+	}
+	if o.DeleteBeforeReplace != nil {
+		out["deleteBeforeReplace"] = *o.DeleteBeforeReplace
+	}
+	if len(o.IgnoreChanges) > 0 {
+		out["ignoreChanges"] = o.IgnoreChanges
+	}
+	if len(o.ReplaceOnChanges) > 0 {
+		out["replaceOnChanges"] = o.ReplaceOnChanges
+	}
+	if o.CustomTimeouts != nil {
+		out["customTimeouts"] = o.CustomTimeouts
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
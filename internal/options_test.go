@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResourceOptions_Merge(t *testing.T) {
+	base := ResourceOptions{
+		Protect:        boolRef(true),
+		IgnoreChanges:  []string{"a"},
+		CustomTimeouts: &CustomTimeouts{Create: "10m", Delete: "5m"},
+	}
+	out := base.Merge(ResourceOptions{
+		Protect:        boolRef(false),
+		RetainOnDelete: boolRef(true),
+		IgnoreChanges:  []string{"a", "b"},
+		CustomTimeouts: &CustomTimeouts{Delete: "1h"},
+	})
+	assert.Equal(t, ResourceOptions{
+		Protect:        boolRef(false),
+		RetainOnDelete: boolRef(true),
+		IgnoreChanges:  []string{"a", "b"},
+		CustomTimeouts: &CustomTimeouts{Create: "10m", Delete: "1h"},
+	}, out)
+	assert.Equal(t, []string{"a"}, base.IgnoreChanges)
+	assert.Equal(t, "5m", base.CustomTimeouts.Delete)
+}
+
+func Test_parseOptionsAnnotation(t *testing.T) {
+	o, err := parseOptionsAnnotation(map[string]interface{}{"name": "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, ResourceOptions{}, o)
+
+	o, err = parseOptionsAnnotation(map[string]interface{}{"annotations": map[string]interface{}{
+		OptionsAnnotation: `{"protect": true, "ignore_changes": ["size"]}`,
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, ResourceOptions{Protect: boolRef(true), IgnoreChanges: []string{"size"}}, o)
+
+	_, err = parseOptionsAnnotation(map[string]interface{}{"annotations": map[string]interface{}{OptionsAnnotation: "protecc: true"}})
+	assert.ErrorContains(t, err, "annotation scorpion.dev/options: yaml: unmarshal errors:\n  line 1: field protecc not found")
+	_, err = parseOptionsAnnotation(map[string]interface{}{"annotations": map[string]interface{}{OptionsAnnotation: "custom_timeouts: {create: soon}"}})
+	assert.EqualError(t, err, `annotation scorpion.dev/options: invalid create timeout 'soon': time: invalid duration "soon"`)
+}
+
+func TestBuildJenFile_options(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "annotations": map[string]interface{}{
+					OptionsAnnotation: "delete_before_replace: true",
+				}},
+				Resources: map[string]types.Resource{
+					"db": {Type: "postgres", Id: ref("db"), Metadata: map[string]interface{}{"annotations": map[string]interface{}{
+						OptionsAnnotation: "{ignore_changes: [password], custom_timeouts: {create: 30m}}",
+					}}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs", Options: &ResourceOptions{
+					Protect:          boolRef(true),
+					RetainOnDelete:   boolRef(true),
+					IgnoreChanges:    []string{"size"},
+					ReplaceOnChanges: []string{"engine"},
+				}},
+				ResourceType: "postgres",
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `sharedDbf876cb74, err := comp.NewDatabase(ctx, "shared.db", &comp.DatabaseArgs{}, pulumi.Protect(true), pulumi.RetainOnDelete(true), pulumi.IgnoreChanges([]string{"size", "password"}), pulumi.ReplaceOnChanges([]string{"engine"}), pulumi.Timeouts(&pulumi.CustomTimeouts{Create: "30m"}))`)
+	assert.Contains(t, out, `pulumi.DeleteBeforeReplace(true), pulumi.DependsOn([]pulumi.Resource{sharedDbf876cb74}))`)
+
+	cfg.ResourceComponents[0].Options.CustomTimeouts = &CustomTimeouts{Update: "x"}
+	_, err = cfg.GenerateComponentGraph()
+	assert.EqualError(t, err, `config contains an invalid resource component spec (0): component contains invalid options: invalid update timeout 'x': time: invalid duration "x"`)
+}
+
+func TestBuildPulumiYaml_options(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "annotations": map[string]interface{}{
+					OptionsAnnotation: "delete_before_replace: true",
+				}},
+				Resources: map[string]types.Resource{
+					"db": {Type: "postgres", Id: ref("db"), Metadata: map[string]interface{}{"annotations": map[string]interface{}{
+						OptionsAnnotation: "{ignore_changes: [password], custom_timeouts: {create: 30m}}",
+					}}},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs", YamlType: "comp:index:Workload"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs", YamlType: "comp:index:Database", Options: &ResourceOptions{
+					Protect:          boolRef(true),
+					RetainOnDelete:   boolRef(true),
+					IgnoreChanges:    []string{"size"},
+					ReplaceOnChanges: []string{"engine"},
+				}},
+				ResourceType: "postgres",
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	doc, err := BuildPulumiYaml(g, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Contains(t, buff.String(), `    sharedDbf876cb74:
+        name: shared.db
+        options:
+            customTimeouts:
+                create: 30m
+            ignoreChanges:
+                - size
+                - password
+            protect: true
+            replaceOnChanges:
+                - engine
+            retainOnDelete: true
+`)
+	assert.Contains(t, buff.String(), `        options:
+            deleteBeforeReplace: true
+`)
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/score-spec/score-go/framework"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOverridesFile decodes a yaml file of overrides to merge into a Score workload.
+func LoadOverridesFile(fileName string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overrides file: %w", err)
+	}
+	var out map[string]interface{}
+	if err := yaml.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("failed to decode overrides file '%s': %w", fileName, err)
+	}
+	return out, nil
+}
+
+// ApplyOverrides merges the overrides map into the decoded Score workload and then applies each override property in
+// order. A property of the form path=value sets the path to the yaml-decoded value, while -path deletes it. Paths are
+// dot-separated and may use backslashes to escape dots within keys.
+func ApplyOverrides(srcMap map[string]interface{}, overrides map[string]interface{}, properties []string) (map[string]interface{}, error) {
+	var err error
+	if overrides != nil {
+		if srcMap, err = framework.OverrideMapInMap(srcMap, overrides); err != nil {
+			return nil, fmt.Errorf("failed to apply overrides file: %w", err)
+		}
+	}
+	for _, p := range properties {
+		if path, ok := strings.CutPrefix(p, "-"); ok && !strings.Contains(path, "=") {
+			if path == "" {
+				return nil, fmt.Errorf("invalid override property '%s': expected path=value or -path", p)
+			}
+			if srcMap, err = framework.OverridePathInMap(srcMap, framework.ParseDotPathParts(path), true, nil); err != nil {
+				return nil, fmt.Errorf("failed to apply override property '%s': %w", p, err)
+			}
+			continue
+		}
+		path, raw, ok := strings.Cut(p, "=")
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid override property '%s': expected path=value or -path", p)
+		}
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("invalid override property '%s': %w", p, err)
+		}
+		if srcMap, err = framework.OverridePathInMap(srcMap, framework.ParseDotPathParts(path), false, value); err != nil {
+			return nil, fmt.Errorf("failed to apply override property '%s': %w", p, err)
+		}
+	}
+	return srcMap, nil
+}
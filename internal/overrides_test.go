@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	out, err := ApplyOverrides(map[string]interface{}{
+		"apiVersion": "score.dev/v1b1",
+		"metadata":   map[string]interface{}{"name": "foo"},
+		"containers": map[string]interface{}{
+			"main": map[string]interface{}{
+				"image":     "nginx",
+				"variables": map[string]interface{}{"A": "1", "B": "2"},
+			},
+		},
+		"resources": map[string]interface{}{
+			"db": map[string]interface{}{"type": "postgres", "params": map[string]interface{}{"size": 1}},
+		},
+	}, map[string]interface{}{
+		"containers": map[string]interface{}{
+			"main": map[string]interface{}{"image": "nginx:1.27", "variables": map[string]interface{}{"A": nil}},
+		},
+	}, []string{
+		"containers.main.variables.C=3",
+		"resources.db.params.size=5",
+		"-containers.main.variables.B",
+		"metadata.annotations.example\\.com/key=value",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "score.dev/v1b1",
+		"metadata":   map[string]interface{}{"name": "foo", "annotations": map[string]interface{}{"example.com/key": "value"}},
+		"containers": map[string]interface{}{
+			"main": map[string]interface{}{
+				"image":     "nginx:1.27",
+				"variables": map[string]interface{}{"C": 3},
+			},
+		},
+		"resources": map[string]interface{}{
+			"db": map[string]interface{}{"type": "postgres", "params": map[string]interface{}{"size": 5}},
+		},
+	}, out)
+}
+
+func TestApplyOverrides_does_not_modify_input(t *testing.T) {
+	in := map[string]interface{}{
+		"containers": map[string]interface{}{"main": map[string]interface{}{"image": "nginx"}},
+		"resources":  map[string]interface{}{"db": map[string]interface{}{"type": "postgres"}},
+	}
+	_, err := ApplyOverrides(in, nil, []string{"containers.main.image=busybox", "-resources.db"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"containers": map[string]interface{}{"main": map[string]interface{}{"image": "nginx"}},
+		"resources":  map[string]interface{}{"db": map[string]interface{}{"type": "postgres"}},
+	}, in)
+}
+
+func TestApplyOverrides_invalid(t *testing.T) {
+	in := map[string]interface{}{"containers": map[string]interface{}{"main": map[string]interface{}{"image": "nginx"}}}
+	_, err := ApplyOverrides(in, nil, []string{"nope"})
+	assert.EqualError(t, err, "invalid override property 'nope': expected path=value or -path")
+	_, err = ApplyOverrides(in, nil, []string{"-"})
+	assert.EqualError(t, err, "invalid override property '-': expected path=value or -path")
+	_, err = ApplyOverrides(in, nil, []string{"containers.main.image.x=y"})
+	assert.EqualError(t, err, "failed to apply override property 'containers.main.image.x=y': containers: main: image: cannot set path in non-map/non-array")
+}
+
+func TestLoadOverridesFile(t *testing.T) {
+	td := t.TempDir()
+	p := filepath.Join(td, "overrides.yaml")
+	require.NoError(t, os.WriteFile(p, []byte("containers:\n  main:\n    image: busybox\n"), 0644))
+	out, err := LoadOverridesFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"containers": map[string]interface{}{"main": map[string]interface{}{"image": "busybox"}}}, out)
+
+	require.NoError(t, os.WriteFile(p, []byte("- a\n"), 0644))
+	_, err = LoadOverridesFile(p)
+	assert.ErrorContains(t, err, "failed to decode overrides file")
+}
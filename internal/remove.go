@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/score-spec/score-go/types"
+)
+
+type RemovedWorkload struct {
+	// OrphanedSharedResources are the shared.<id> resources that the removed workload referenced and that no remaining
+	// workload references.
+	OrphanedSharedResources []string
+	// MovedSharedParams maps each shared.<id> resource whose params were defined by the removed workload to the
+	// remaining workload that now defines them. The value is empty if no remaining workload defines params for it.
+	MovedSharedParams map[string]string
+}
+
+// sharedResourceParamOwners returns the name of the workload defining the params for each shared resource. Like
+// GenerateComponentGraph, this is the first workload in the list which sets params on the resource.
+func sharedResourceParamOwners(workloads []types.Workload) map[string]string {
+	out := make(map[string]string)
+	for _, workload := range workloads {
+		workloadName := workload.Metadata["name"].(string)
+		for _, alias := range slices.Sorted(maps.Keys(workload.Resources)) {
+			res := workload.Resources[alias]
+			if res.Id == nil || res.Params == nil {
+				continue
+			}
+			resId, _ := resolveResourceIdAndClass(workloadName, alias, res)
+			if _, ok := out[resId]; !ok {
+				out[resId] = workloadName
+			}
+		}
+	}
+	return out
+}
+
+// sharedResourceIds returns the set of shared.<id> resources referenced by the workloads.
+func sharedResourceIds(workloads ...types.Workload) map[string]bool {
+	out := make(map[string]bool)
+	for _, workload := range workloads {
+		workloadName := workload.Metadata["name"].(string)
+		for alias, res := range workload.Resources {
+			if res.Id != nil {
+				resId, _ := resolveResourceIdAndClass(workloadName, alias, res)
+				out[resId] = true
+			}
+		}
+	}
+	return out
+}
+
+// RemoveWorkload removes the named workload from the config and reports the shared resources affected by its removal.
+func (cfg *ScoreConfig) RemoveWorkload(name string) (RemovedWorkload, error) {
+	i := slices.IndexFunc(cfg.Workloads, func(w types.Workload) bool {
+		return w.Metadata["name"].(string) == name
+	})
+	if i < 0 {
+		return RemovedWorkload{}, fmt.Errorf("workload '%s' does not exist", name)
+	}
+	removed := cfg.Workloads[i]
+	ownersBefore := sharedResourceParamOwners(cfg.Workloads)
+	cfg.Workloads = slices.Delete(cfg.Workloads, i, i+1)
+	ownersAfter := sharedResourceParamOwners(cfg.Workloads)
+
+	out := RemovedWorkload{
+		OrphanedSharedResources: make([]string, 0),
+		MovedSharedParams:       make(map[string]string),
+	}
+	remaining := sharedResourceIds(cfg.Workloads...)
+	for _, resId := range slices.Sorted(maps.Keys(sharedResourceIds(removed))) {
+		if !remaining[resId] {
+			out.OrphanedSharedResources = append(out.OrphanedSharedResources, resId)
+		} else if ownersBefore[resId] == name {
+			out.MovedSharedParams[resId] = ownersAfter[resId]
+		}
+	}
+	return out, nil
+}
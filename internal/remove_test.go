@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveWorkload(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo"},
+				Resources: map[string]types.Resource{
+					"a":  {Type: "thing"},
+					"db": {Type: "postgres", Id: ref("db"), Params: map[string]interface{}{"size": "large"}},
+					"q":  {Type: "queue", Id: ref("q"), Params: map[string]interface{}{"fifo": true}},
+					"o":  {Type: "thing", Id: ref("orphan")},
+				},
+			},
+			{
+				Metadata: map[string]interface{}{"name": "bar"},
+				Resources: map[string]types.Resource{
+					"db": {Type: "postgres", Id: ref("db"), Params: map[string]interface{}{"size": "large"}},
+					"q":  {Type: "queue", Id: ref("q")},
+				},
+			},
+		},
+	}
+	r, err := cfg.RemoveWorkload("foo")
+	require.NoError(t, err)
+	assert.Equal(t, RemovedWorkload{
+		OrphanedSharedResources: []string{"shared.orphan"},
+		MovedSharedParams: map[string]string{
+			"shared.db": "bar",
+			"shared.q":  "",
+		},
+	}, r)
+	require.Len(t, cfg.Workloads, 1)
+	assert.Equal(t, "bar", cfg.Workloads[0].Metadata["name"])
+
+	t.Run("unknown workload", func(t *testing.T) {
+		_, err := cfg.RemoveWorkload("foo")
+		assert.EqualError(t, err, "workload 'foo' does not exist")
+	})
+
+	t.Run("last workload", func(t *testing.T) {
+		r, err := cfg.RemoveWorkload("bar")
+		require.NoError(t, err)
+		assert.Equal(t, RemovedWorkload{
+			OrphanedSharedResources: []string{"shared.db", "shared.q"},
+			MovedSharedParams:       map[string]string{},
+		}, r)
+		assert.Empty(t, cfg.Workloads)
+	})
+}
@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+
+	"github.com/dave/jennifer/jen"
+	"github.com/score-spec/score-go/framework"
+)
+
+// secretValue marks a param value as sensitive so that the generators wrap the value they produce as a secret.
+type secretValue struct {
+	value interface{}
+}
+
+// buildSecretRefChecker returns a function that reports whether a ${resources.<alias>.<field>} reference refers to a
+// secret output of a dependency.
+func buildSecretRefChecker(g ComponentGraph, dependencies map[LocalAlias]ComponentGoIdentifier) func(ref string) bool {
+	return func(ref string) bool {
+		parts := framework.SplitRefParts(ref)
+		if len(parts) < 3 || parts[0] != "resources" {
+			return false
+		}
+		dep, ok := dependencies[LocalAlias(parts[1])]
+		return ok && slices.Contains(g.Nodes[dep].SecretOutputs, parts[2])
+	}
+}
+
+// markSecretRefs returns a copy of the param value where strings that reference a secret output are marked as
+// secretValues. Invalid references are left for the generators to report.
+func markSecretRefs(raw interface{}, isSecretRef func(ref string) bool) interface{} {
+	switch typed := raw.(type) {
+	case string:
+		if !strings.Contains(typed, "${") {
+			return typed
+		}
+		var secret bool
+		_, _ = framework.SubstituteString(typed, func(ref string) (string, error) {
+			secret = secret || isSecretRef(ref)
+			return "", nil
+		})
+		if secret {
+			return secretValue{value: typed}
+		}
+		return typed
+	case []interface{}:
+		out := make([]interface{}, 0, len(typed))
+		for _, v := range typed {
+			out = append(out, markSecretRefs(v, isSecretRef))
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			out[k] = markSecretRefs(v, isSecretRef)
+		}
+		return out
+	default:
+		return typed
+	}
+}
+
+// mergeSecretParams merges the params and fixed params of the component, with fixed params taking precedence, and
+// marks the values that must be wrapped as secrets.
+func mergeSecretParams(g ComponentGraph, id ComponentGoIdentifier) map[string]interface{} {
+	n := g.Nodes[id]
+	params := make(map[string]interface{}, len(n.Params)+len(n.FixedParams))
+	isSecretRef := buildSecretRefChecker(g, g.Dependencies[id])
+	for k, v := range n.Params {
+		params[k] = markSecretRefs(v, isSecretRef)
+	}
+	for k, v := range n.FixedParams {
+		if slices.Contains(n.SecretFixedParams, k) {
+			v = secretValue{value: v}
+		}
+		params[k] = v
+	}
+	return params
+}
+
+// wrapSecret wraps the code of a value in pulumi.ToSecret and asserts the result back to the output type so that it
+// is still assignable to the matching input type.
+func wrapSecret(code jen.Code, outputType *jen.Statement) *jen.Statement {
+	c := jen.Qual(DefaultPulumiPackage, "ToSecret").Call(code)
+	if outputType != nil {
+		c = c.Assert(outputType)
+	}
+	return c
+}
+
+// untypedSecretOutputType returns the output type that pulumi.ToSecret produces for the untyped wrapper of the value.
+func untypedSecretOutputType(raw interface{}) *jen.Statement {
+	switch raw.(type) {
+	case string:
+		return jen.Qual(DefaultPulumiPackage, "StringOutput")
+	case bool:
+		return jen.Qual(DefaultPulumiPackage, "BoolOutput")
+	case float64:
+		return jen.Qual(DefaultPulumiPackage, "Float64Output")
+	case int:
+		return jen.Qual(DefaultPulumiPackage, "IntOutput")
+	case []interface{}:
+		return jen.Qual(DefaultPulumiPackage, "ArrayOutput")
+	case map[string]interface{}:
+		return jen.Qual(DefaultPulumiPackage, "MapOutput")
+	default:
+		return nil
+	}
+}
+
+// typedSecretOutputType returns the output type matching an input interface such as pulumi.StringOutput for
+// pulumi.StringInput. Generic inputs such as pulumi.Input accept any output and need no assertion. Pointer inputs such
+// as pulumi.StringPtrInput are given concrete values whose secret is a pulumi.StringOutput, so the returned conversion
+// method such as ToStringPtrOutput must be called on the asserted output.
+func typedSecretOutputType(path []string, target types.Type) (*jen.Statement, string, error) {
+	if named, ok := target.(*types.Named); ok {
+		if _, ok := named.Underlying().(*types.Interface); ok {
+			if base, ok := strings.CutSuffix(named.Obj().Name(), "Input"); !ok {
+				return nil, "", fmt.Errorf("cannot mark %s as secret since %s is not an input type", strings.Join(path, "."), target)
+			} else if base == "" {
+				return nil, "", nil
+			} else if elem, ok := strings.CutSuffix(base, "Ptr"); ok && elem != "" {
+				return jen.Qual(named.Obj().Pkg().Path(), elem+"Output"), "To" + base + "Output", nil
+			} else {
+				return jen.Qual(named.Obj().Pkg().Path(), base+"Output"), "", nil
+			}
+		}
+	} else if iface, ok := target.(*types.Interface); ok && iface.Empty() {
+		return nil, "", nil
+	}
+	return nil, "", fmt.Errorf("cannot mark %s as secret since %s is not an input type", strings.Join(path, "."), target)
+}
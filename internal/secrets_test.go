@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBuildJenFile_secrets(t *testing.T) {
+	f, err := BuildJenFile(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedDbf876cb74": {
+				Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "shared.db",
+				FixedParams:       map[string]interface{}{"token": "abc", "tier": "gold"},
+				SecretFixedParams: []string{"token"},
+				Exports:           []string{"host", "password"},
+				SecretOutputs:     []string{"password"},
+			},
+			"workloadFoo6d39e786": {
+				Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "workload.foo",
+				Params: map[string]interface{}{
+					"values": map[string]interface{}{
+						"url":  "postgres://${resources.db.host}:${resources.db.password}@db",
+						"host": "${resources.db.host}",
+					},
+				},
+			},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"workloadFoo6d39e786": {"db": "sharedDbf876cb74"},
+		},
+	})
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `Token: pulumi.ToSecret(pulumi.String("abc")).(pulumi.StringOutput),`)
+	assert.Contains(t, out, `"password": pulumi.ToSecret(sharedDbf876cb74.Password),`)
+	assert.Contains(t, out, `"host": pulumi.Sprintf("%v", sharedDbf876cb74.Host),`)
+	assert.Contains(t, out, `"url":  pulumi.ToSecret(pulumi.Sprintf("postgres://%v:%v@db", sharedDbf876cb74.Host, sharedDbf876cb74.Password)).(pulumi.StringOutput),`)
+}
+
+func TestBuildJenFile_typed_secrets(t *testing.T) {
+	argsType, outputsType := fakeComponentTypes(t)
+	g := ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedDbf876cb74": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "shared.db", argsTypeInfo: argsType, outputsTypeInfo: outputsType, SecretOutputs: []string{"name"}},
+			"workloadFoo6d39e786": {Package: "example.com/comp", Constructor: "New", ArgsType: "Args", Name: "workload.foo", argsTypeInfo: argsType,
+				Params:            map[string]interface{}{"name": "${resources.db.name}", "tags": []interface{}{"x-${resources.db.name}"}},
+				FixedParams:       map[string]interface{}{"count": 3, "values": map[string]interface{}{"a": 1}, "secret": "abc", "nested": map[string]interface{}{"size": 2}},
+				SecretFixedParams: []string{"count", "values", "secret", "nested"},
+			},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"workloadFoo6d39e786": {"db": "sharedDbf876cb74"},
+		},
+	}
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), `workloadFoo6d39e786, err := comp.New(ctx, "workload.foo", &comp.Args{
+			Count:  pulumi.ToSecret(pulumi.Int(3)).(pulumi.IntOutput),
+			Name:   pulumi.ToSecret(sharedDbf876cb74.Name).(pulumi.StringOutput),
+			Nested: pulumi.ToSecret(comp.NestedArgs{Size: pulumi.Int(2)}).(comp.NestedOutput).ToNestedPtrOutput(),
+			Secret: pulumi.ToSecret(pulumi.String("abc")).(pulumi.StringOutput).ToStringPtrOutput(),
+			Tags:   pulumi.StringArray{pulumi.ToSecret(pulumi.Sprintf("x-%v", sharedDbf876cb74.Name)).(pulumi.StringOutput)},
+			Values: pulumi.ToSecret(pulumi.Map{"a": pulumi.Int(1)}).(pulumi.MapOutput),
+		})`)
+
+	n := g.Nodes["workloadFoo6d39e786"]
+	n.FixedParams = map[string]interface{}{"plain": "x"}
+	n.SecretFixedParams = []string{"plain"}
+	g.Nodes["workloadFoo6d39e786"] = n
+	_, err = BuildJenFile(g)
+	assert.EqualError(t, err, "workload.foo: cannot mark plain as secret since string is not an input type")
+}
+
+func TestBuildPulumiYaml_secrets(t *testing.T) {
+	doc, err := BuildPulumiYaml(ComponentGraph{
+		Nodes: map[ComponentGoIdentifier]ComponentInstance{
+			"sharedDbf876cb74": {
+				YamlType: "comp:index:Db", Name: "shared.db",
+				FixedParams:       map[string]interface{}{"token": "abc", "tier": "gold"},
+				SecretFixedParams: []string{"token"},
+				Exports:           []string{"host", "password"},
+				SecretOutputs:     []string{"password"},
+			},
+			"workloadFoo6d39e786": {
+				YamlType: "comp:index:Workload", Name: "workload.foo",
+				Params: map[string]interface{}{
+					"values": map[string]interface{}{
+						"url":  "postgres://${resources.db.host}:${resources.db.password}@db",
+						"host": "${resources.db.host}",
+					},
+				},
+			},
+		},
+		Dependencies: map[ComponentGoIdentifier]map[LocalAlias]ComponentGoIdentifier{
+			"workloadFoo6d39e786": {"db": "sharedDbf876cb74"},
+		},
+	}, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	out := buff.String()
+	assert.Contains(t, out, `
+            token:
+                fn::secret: abc
+`)
+	assert.Contains(t, out, `
+                url:
+                    fn::secret: postgres://${sharedDbf876cb74.host}:${sharedDbf876cb74.password}@db
+`)
+	assert.Contains(t, out, `
+        password:
+            fn::secret: ${sharedDbf876cb74.password}
+`)
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/dave/jennifer/jen"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateComponentType is the type of the component resource that holds the outputs of a template component.
+const TemplateComponentType = "scorpion:template"
+
+// ComponentTemplate declares the outputs of a resource component that is generated within the program instead of
+// being constructed from a Go package.
+type ComponentTemplate struct {
+	// Outputs is either a Go template that renders a yaml map of outputs or a literal map of outputs. Rendered and
+	// literal outputs may both contain ${...} placeholders, including references to the outputs of other resources.
+	// Templates should encode inserted values with the toYaml func, such as {{ .Params.host | toYaml }}.
+	Outputs interface{} `yaml:"outputs"`
+}
+
+// templateData is the data that the outputs template is executed with.
+type templateData struct {
+	// Id is the component name such as shared.<id> or workload.<name>.<alias>
+	Id               string
+	Type             string
+	Class            string
+	Params           map[string]interface{}
+	Metadata         map[string]interface{}
+	WorkloadMetadata map[string]interface{}
+}
+
+// parseOutputsTemplate parses a Go template of outputs. Missing map keys are errors rather than empty values.
+func parseOutputsTemplate(raw string) (*template.Template, error) {
+	return template.New("outputs").Option("missingkey=error").Funcs(template.FuncMap{"toYaml": toYamlValue}).Parse(raw)
+}
+
+// toYamlValue encodes a value as a single line of yaml in the flow style so that it can be placed after a key in the
+// outputs template without the value changing the structure of the document.
+func toYamlValue(v interface{}) (string, error) {
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return "", err
+	}
+	setYamlFlowStyle(&node)
+	raw, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(raw), "\n"), nil
+}
+
+// setYamlFlowStyle sets the flow style on the node and its children, and double quotes multi-line strings so that
+// they are escaped onto a single line.
+func setYamlFlowStyle(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		node.Style = yaml.FlowStyle
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "\n") {
+			node.Style = yaml.DoubleQuotedStyle
+		}
+	}
+	for _, c := range node.Content {
+		setYamlFlowStyle(c)
+	}
+}
+
+// Validate returns an error if the outputs are neither a valid Go template nor a map of valid output names.
+func (t ComponentTemplate) Validate() error {
+	switch typed := t.Outputs.(type) {
+	case string:
+		if _, err := parseOutputsTemplate(typed); err != nil {
+			return fmt.Errorf("invalid outputs template: %w", err)
+		}
+		return nil
+	case map[string]interface{}:
+		return validateTemplateOutputs(typed)
+	default:
+		return fmt.Errorf("template outputs must be a Go template string or a map")
+	}
+}
+
+// validateTemplateOutputs returns an error if an output name is not a valid field name or if two names would become
+// the same field of the generated struct.
+func validateTemplateOutputs(outputs map[string]interface{}) error {
+	fields := make(map[string]string, len(outputs))
+	for _, k := range slices.Sorted(maps.Keys(outputs)) {
+		if !validOutputFieldPattern.MatchString(k) {
+			return fmt.Errorf("template contains an invalid output field '%s'", k)
+		}
+		field := toParamName(k).GoString()
+		if other, ok := fields[field]; ok {
+			return fmt.Errorf("template outputs '%s' and '%s' would both become the %s field", other, k, field)
+		}
+		fields[field] = k
+	}
+	return nil
+}
+
+// render returns the outputs of the template for a resource. A Go template must render a yaml map.
+func (t ComponentTemplate) render(data templateData) (map[string]interface{}, error) {
+	var outputs map[string]interface{}
+	switch typed := t.Outputs.(type) {
+	case string:
+		tmpl, err := parseOutputsTemplate(typed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid outputs template: %w", err)
+		}
+		buff := new(bytes.Buffer)
+		if err := tmpl.Execute(buff, data); err != nil {
+			return nil, fmt.Errorf("failed to render outputs template: %w", err)
+		}
+		if err := yaml.Unmarshal(buff.Bytes(), &outputs); err != nil {
+			return nil, fmt.Errorf("outputs template did not render a yaml map: %w", err)
+		}
+		if outputs == nil {
+			outputs = make(map[string]interface{})
+		}
+	case map[string]interface{}:
+		outputs = typed
+	default:
+		return nil, fmt.Errorf("template outputs must be a Go template string or a map")
+	}
+	if err := validateTemplateOutputs(outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// templateOutputType returns the name of the pulumi output type, without the Output suffix, that holds the value.
+func templateOutputType(path string, raw interface{}) (string, error) {
+	if s, ok := raw.(secretValue); ok {
+		raw = s.value
+	}
+	switch raw.(type) {
+	case string:
+		return "String", nil
+	case bool:
+		return "Bool", nil
+	case float64:
+		return "Float64", nil
+	case int:
+		return "Int", nil
+	case []interface{}:
+		return "Array", nil
+	case map[string]interface{}:
+		return "Map", nil
+	default:
+		return "", fmt.Errorf("template output '%s' has no value or an unsupported type %T", path, raw)
+	}
+}
+
+// buildTemplateComponent returns the statements that construct a template component and register its outputs, along
+// with the declaration of the struct type that holds them. Components with the same output fields share the type.
+func buildTemplateComponent(g ComponentGraph, id ComponentGoIdentifier, options []jen.Code, substFunc func(fmtArgs *[]jen.Code) func(s string) (string, error)) ([]jen.Code, string, jen.Code, error) {
+	n := g.Nodes[id]
+	outputs := markSecretRefs(n.TemplateOutputs, buildSecretRefChecker(g, g.Dependencies[id])).(map[string]interface{})
+	fieldDecls := []jen.Code{jen.Qual(DefaultPulumiPackage, "ResourceState")}
+	fieldValues := make(jen.Dict, len(outputs))
+	registered := make(jen.Dict, len(outputs))
+	signature := make([]string, 0, len(outputs))
+	for _, k := range slices.Sorted(maps.Keys(outputs)) {
+		outputType, err := templateOutputType(k, outputs[k])
+		if err != nil {
+			return nil, "", nil, err
+		}
+		value, err := pulumifyValue([]string{k}, outputs[k], substFunc)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		field := toParamName(k).GoString()
+		signature = append(signature, field+":"+outputType)
+		fieldDecls = append(fieldDecls, jen.Id(field).Qual(DefaultPulumiPackage, outputType+"Output"))
+		fieldValues[jen.Id(field)] = jen.Add(value).Dot("To" + outputType + "Output").Call()
+		registered[jen.Lit(k)] = jen.Id(string(id)).Dot(field)
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join(signature, ",")))
+	typeName := "templateComponent" + hex.EncodeToString(h.Sum(nil))
+	typeDecl := jen.Comment(typeName + " holds the outputs of template components with the fields " + strings.Join(signature, ", ")).Line().
+		Type().Id(typeName).Struct(fieldDecls...)
+
+	registerArgs := append([]jen.Code{jen.Lit(TemplateComponentType), jen.Lit(n.Name), jen.Id(string(id))}, options...)
+	return []jen.Code{
+		jen.Id(string(id)).Op(":=").Op("&").Id(typeName).Values(fieldValues),
+		jen.If(
+			jen.Err().Op(":=").Id("ctx").Dot("RegisterComponentResource").Call(registerArgs...),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Err())),
+		jen.If(
+			jen.Err().Op(":=").Id("ctx").Dot("RegisterResourceOutputs").Call(jen.Id(string(id)), jen.Qual(DefaultPulumiPackage, "Map").Values(registered)),
+			jen.Err().Op("!=").Nil(),
+		).Block(jen.Return(jen.Err())),
+	}, typeName, typeDecl, nil
+}
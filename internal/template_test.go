@@ -0,0 +1,250 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/score-spec/score-go/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestComponentTemplate_Validate(t *testing.T) {
+	assert.NoError(t, ComponentTemplate{Outputs: "host: {{ .Params.host }}"}.Validate())
+	assert.NoError(t, ComponentTemplate{Outputs: map[string]interface{}{"host": "localhost"}}.Validate())
+	assert.EqualError(t, ComponentTemplate{Outputs: "host: {{ .Params.host "}.Validate(), "invalid outputs template: template: outputs:1: unclosed action")
+	assert.EqualError(t, ComponentTemplate{Outputs: map[string]interface{}{"not-a-field": 1}}.Validate(), "template contains an invalid output field 'not-a-field'")
+	assert.EqualError(t, ComponentTemplate{Outputs: map[string]interface{}{"db_host": 1, "dbHost": 2}}.Validate(), "template outputs 'dbHost' and 'db_host' would both become the DbHost field")
+	assert.EqualError(t, ComponentTemplate{Outputs: 42}.Validate(), "template outputs must be a Go template string or a map")
+}
+
+func TestComponentTemplate_render(t *testing.T) {
+	data := templateData{Id: "workload.foo.api", Type: "endpoint", Params: map[string]interface{}{"host": "api"}, WorkloadMetadata: map[string]interface{}{"name": "foo"}}
+	outputs, err := ComponentTemplate{Outputs: "host: {{ .Params.host }}.{{ .WorkloadMetadata.name }}\nid: {{ .Id }}"}.render(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"host": "api.foo", "id": "workload.foo.api"}, outputs)
+
+	outputs, err = ComponentTemplate{Outputs: ""}.render(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{}, outputs)
+
+	data.Params["motd"] = "hello: world # not a comment\n- not a list"
+	outputs, err = ComponentTemplate{Outputs: "motd: {{ .Params.motd | toYaml }}\nparams: {{ .Params | toYaml }}"}.render(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"motd": data.Params["motd"], "params": data.Params}, outputs)
+
+	_, err = ComponentTemplate{Outputs: "port: {{ .Params.port }}"}.render(data)
+	assert.EqualError(t, err, `failed to render outputs template: template: outputs:1:16: executing "outputs" at <.Params.port>: map has no entry for key "port"`)
+	_, err = ComponentTemplate{Outputs: "- a\n- b"}.render(data)
+	assert.ErrorContains(t, err, "outputs template did not render a yaml map")
+	_, err = ComponentTemplate{Outputs: "bad-name: 1"}.render(data)
+	assert.EqualError(t, err, "template contains an invalid output field 'bad-name'")
+}
+
+func TestValidateResourceComponentEntry_template(t *testing.T) {
+	entry := ResourceComponentEntry{ResourceType: "thing", Template: &ComponentTemplate{Outputs: map[string]interface{}{"host": "localhost"}}}
+	assert.NoError(t, ValidateResourceComponentEntry(entry))
+	assert.Equal(t, "template", entry.String())
+	t.Run("with package", func(t *testing.T) {
+		entry := entry
+		entry.Package = "github.com/example/thing"
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "template component cannot also have a package, constructor_func, or args_struct")
+	})
+	t.Run("with provider", func(t *testing.T) {
+		entry := entry
+		entry.Provider = "east"
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "template component cannot have a provider")
+	})
+	t.Run("bad outputs", func(t *testing.T) {
+		entry := entry
+		entry.Template = &ComponentTemplate{Outputs: []interface{}{"host"}}
+		assert.EqualError(t, ValidateResourceComponentEntry(entry), "template outputs must be a Go template string or a map")
+	})
+}
+
+func TestGenerateComponentGraph_template(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "team": "core"},
+				Resources: map[string]types.Resource{
+					"api": {Type: "endpoint", Params: map[string]interface{}{"host": "api.example.com", "port": 8443}},
+					"db":  {Type: "postgres"},
+					"env": {Type: "environment"},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Exports: []string{"url"}},
+				ResourceType:   "endpoint",
+				Template: &ComponentTemplate{Outputs: `host: {{ .Params.host | toYaml }}
+port: {{ .Params.port | toYaml }}
+url: {{ printf "https://%s:%v/%s" .Params.host .Params.port .WorkloadMetadata.team | toYaml }}`},
+			},
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"},
+				ResourceType:   "postgres",
+			},
+			{
+				ResourceType: "environment",
+				Template: &ComponentTemplate{Outputs: map[string]interface{}{
+					"DB_HOST":  "${resources.db.host}",
+					"API_URL":  "${resources.api.url}",
+					"TEAM":     "${metadata.team}",
+					"FEATURES": []interface{}{"a", "b"},
+				}},
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"host": "api.example.com",
+		"port": 8443,
+		"url":  "https://api.example.com:8443/core",
+	}, g.Nodes["workloadFooApi80a77b08"].TemplateOutputs)
+	assert.Equal(t, map[string]interface{}{
+		"API_URL":  "${resources.api.url}",
+		"DB_HOST":  "${resources.db.host}",
+		"FEATURES": []interface{}{"a", "b"},
+		"TEAM":     "core",
+	}, g.Nodes["workloadFooEnv340cc5a7"].TemplateOutputs)
+	assert.Equal(t, map[LocalAlias]ComponentGoIdentifier{
+		"api": "workloadFooApi80a77b08",
+		"db":  "workloadFooDb06fdf2c2",
+	}, g.Dependencies["workloadFooEnv340cc5a7"])
+
+	cfg.Workloads[0].Resources["api"] = types.Resource{Type: "endpoint", Params: map[string]interface{}{"host": "api.example.com"}}
+	_, err = cfg.GenerateComponentGraph()
+	assert.ErrorContains(t, err, "resource 'workload.foo.api': failed to render outputs template")
+}
+
+func TestBuildJenFile_template(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "team": "core"},
+				Resources: map[string]types.Resource{
+					"api": {Type: "endpoint", Params: map[string]interface{}{"host": "api.example.com", "port": 8443}},
+					"db":  {Type: "postgres"},
+					"env": {Type: "environment"},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Exports: []string{"url"}},
+				ResourceType:   "endpoint",
+				Template: &ComponentTemplate{Outputs: `host: {{ .Params.host | toYaml }}
+port: {{ .Params.port | toYaml }}
+url: {{ printf "https://%s:%v/%s" .Params.host .Params.port .WorkloadMetadata.team | toYaml }}`},
+			},
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs"},
+				ResourceType:   "postgres",
+			},
+			{
+				ResourceType: "environment",
+				Template: &ComponentTemplate{Outputs: map[string]interface{}{
+					"DB_HOST":  "${resources.db.host}",
+					"API_URL":  "${resources.api.url}",
+					"TEAM":     "${metadata.team}",
+					"FEATURES": []interface{}{"a", "b"},
+				}},
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	f, err := BuildJenFile(g)
+	require.NoError(t, err)
+	out := f.GoString()
+	assert.Contains(t, out, `		workloadFooApi80a77b08 := &templateComponent3dd51da2{
+			Host: pulumi.String("api.example.com").ToStringOutput(),
+			Port: pulumi.Int(8443).ToIntOutput(),
+			Url:  pulumi.String("https://api.example.com:8443/core").ToStringOutput(),
+		}
+		if err := ctx.RegisterComponentResource("scorpion:template", "workload.foo.api", workloadFooApi80a77b08,`)
+	assert.Contains(t, out, `"url": workloadFooApi80a77b08.Url,`)
+	assert.Contains(t, out, `			APIURL:   pulumi.Sprintf("%v", workloadFooApi80a77b08.Url).ToStringOutput(),
+			DBHOST:   pulumi.Sprintf("%v", workloadFooDb06fdf2c2.Host).ToStringOutput(),
+			FEATURES: pulumi.Array{pulumi.String("a"), pulumi.String("b")}.ToArrayOutput(),
+			TEAM:     pulumi.String("core").ToStringOutput(),
+`)
+	assert.Contains(t, out, `// templateComponent3dd51da2 holds the outputs of template components with the fields Host:String, Port:Int, Url:String
+type templateComponent3dd51da2 struct {
+	pulumi.ResourceState
+	Host pulumi.StringOutput
+	Port pulumi.IntOutput
+	Url  pulumi.StringOutput
+}
+`)
+	assert.Contains(t, out, `// templateComponent4f60f7d4 holds the outputs of template components with the fields APIURL:String, DBHOST:String, FEATURES:Array, TEAM:String
+type templateComponent4f60f7d4 struct {`)
+}
+
+func TestBuildPulumiYaml_template(t *testing.T) {
+	cfg := ScoreConfig{
+		Workloads: []types.Workload{
+			{
+				Metadata: map[string]interface{}{"name": "foo", "team": "core"},
+				Resources: map[string]types.Resource{
+					"api": {Type: "endpoint", Params: map[string]interface{}{"host": "api.example.com", "port": 8443}},
+					"db":  {Type: "postgres"},
+					"env": {Type: "environment"},
+				},
+			},
+		},
+		DefaultWorkloadComponent: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewWorkload", ArgsStruct: "WorkloadArgs", YamlType: "comp:index:Workload"},
+		ResourceComponents: []ResourceComponentEntry{
+			{
+				ComponentEntry: ComponentEntry{Exports: []string{"url"}},
+				ResourceType:   "endpoint",
+				Template: &ComponentTemplate{Outputs: `host: {{ .Params.host | toYaml }}
+port: {{ .Params.port | toYaml }}
+url: {{ printf "https://%s:%v/%s" .Params.host .Params.port .WorkloadMetadata.team | toYaml }}`},
+			},
+			{
+				ComponentEntry: ComponentEntry{Package: "example.com/comp", ConstructorFunc: "NewDatabase", ArgsStruct: "DatabaseArgs", YamlType: "comp:index:Database"},
+				ResourceType:   "postgres",
+			},
+			{
+				ResourceType: "environment",
+				Template: &ComponentTemplate{Outputs: map[string]interface{}{
+					"DB_HOST":  "${resources.db.host}",
+					"API_URL":  "${resources.api.url}",
+					"TEAM":     "${metadata.team}",
+					"FEATURES": []interface{}{"a", "b"},
+				}},
+			},
+		},
+	}
+	g, err := cfg.GenerateComponentGraph()
+	require.NoError(t, err)
+	doc, err := BuildPulumiYaml(g, "example")
+	require.NoError(t, err)
+	buff := new(bytes.Buffer)
+	require.NoError(t, yaml.NewEncoder(buff).Encode(doc))
+	assert.Contains(t, buff.String(), `variables:
+    workloadFooApi80a77b08:
+        host: api.example.com
+        port: 8443
+        url: https://api.example.com:8443/core
+    workloadFooEnv340cc5a7:
+        API_URL: ${workloadFooApi80a77b08.url}
+        DB_HOST: ${workloadFooDb06fdf2c2.host}
+        FEATURES:
+            - a
+            - b
+        TEAM: core
+resources:
+`)
+	assert.Contains(t, buff.String(), `outputs:
+    workload.foo.api:
+        url: ${workloadFooApi80a77b08.url}
+`)
+}
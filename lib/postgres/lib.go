@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/pulumi/pulumi-docker/sdk/v4/go/docker"
+	"github.com/pulumi/pulumi-random/sdk/v4/go/random"
+	"github.com/pulumi/pulumi/sdk/v3/go/pulumi"
+)
+
+type Outputs struct {
+	pulumi.ResourceState
+	Host     pulumi.StringOutput `pulumi:"host"`
+	Port     pulumi.IntOutput    `pulumi:"port"`
+	Name     pulumi.StringOutput `pulumi:"name"`
+	Username pulumi.StringOutput `pulumi:"username"`
+	Password pulumi.StringOutput `pulumi:"password"`
+	Uri      pulumi.StringOutput `pulumi:"uri"`
+}
+
+type Inputs struct {
+	// Image is the Postgres image to run, DefaultImage if not set
+	Image pulumi.StringInput `pulumi:"image"`
+	// Network is the Docker network to attach the container to. Containers on the network reach the database by the
+	// name of its container, otherwise the port is published on localhost.
+	Network pulumi.StringInput `pulumi:"network"`
+}
+
+const (
+	resourceType = "scorpion:builtin:Postgres"
+	DefaultImage = "postgres:17-alpine"
+	internalPort = 5432
+)
+
+var (
+	validImagePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._/:@-]*$`)
+)
+
+func New(ctx *pulumi.Context, name string, args *Inputs, opts ...pulumi.ResourceOption) (*Outputs, error) {
+	if args == nil {
+		args = &Inputs{}
+	}
+	instance := &Outputs{}
+	if err := ctx.RegisterComponentResource(resourceType, name, instance, opts...); err != nil {
+		return nil, err
+	}
+	childOpts := []pulumi.ResourceOption{pulumi.Parent(instance)}
+
+	var image pulumi.StringInput = pulumi.String(DefaultImage)
+	if args.Image != nil {
+		image = args.Image.ToStringOutput().ApplyT(func(s string) (string, error) {
+			if !validImagePattern.MatchString(s) {
+				return "", fmt.Errorf("invalid image '%s'", s)
+			}
+			return s, nil
+		}).(pulumi.StringOutput)
+	}
+
+	// postgres identifiers are lowercase so the suffix is too
+	suffix, err := random.NewRandomString(ctx, name+"-suffix", &random.RandomStringArgs{
+		Length:  pulumi.Int(8),
+		Upper:   pulumi.Bool(false),
+		Special: pulumi.Bool(false),
+	}, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+	password, err := random.NewRandomPassword(ctx, name+"-password", &random.RandomPasswordArgs{
+		Length: pulumi.Int(24),
+		// keep the password safe to embed in the uri without escaping
+		Special: pulumi.Bool(false),
+	}, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	instance.Name = pulumi.Sprintf("db_%s", suffix.Result)
+	instance.Username = pulumi.Sprintf("user_%s", suffix.Result)
+	instance.Password = pulumi.ToSecret(password.Result).(pulumi.StringOutput)
+
+	remoteImage, err := docker.NewRemoteImage(ctx, name+"-image", &docker.RemoteImageArgs{
+		Name:        image,
+		KeepLocally: pulumi.Bool(true),
+	}, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	containerArgs := &docker.ContainerArgs{
+		Name:  pulumi.Sprintf("postgres-%s", suffix.Result),
+		Image: remoteImage.ImageId,
+		Envs: pulumi.StringArray{
+			pulumi.Sprintf("POSTGRES_DB=%s", instance.Name),
+			pulumi.Sprintf("POSTGRES_USER=%s", instance.Username),
+			pulumi.Sprintf("POSTGRES_PASSWORD=%s", instance.Password),
+		},
+		Restart: pulumi.String("unless-stopped"),
+		Healthcheck: &docker.ContainerHealthcheckArgs{
+			Tests:    pulumi.ToStringArray([]string{"CMD-SHELL", "pg_isready -U $POSTGRES_USER -d $POSTGRES_DB"}),
+			Interval: pulumi.String("2s"),
+			Timeout:  pulumi.String("2s"),
+			Retries:  pulumi.Int(15),
+		},
+		// the outputs are only useful once the database accepts connections
+		Wait: pulumi.Bool(true),
+	}
+	if args.Network != nil {
+		containerArgs.NetworksAdvanced = docker.ContainerNetworksAdvancedArray{
+			&docker.ContainerNetworksAdvancedArgs{Name: args.Network},
+		}
+	} else {
+		containerArgs.Ports = docker.ContainerPortArray{
+			&docker.ContainerPortArgs{Internal: pulumi.Int(internalPort), Ip: pulumi.String("127.0.0.1")},
+		}
+	}
+	container, err := docker.NewContainer(ctx, name+"-container", containerArgs, childOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Network != nil {
+		instance.Host = container.Name
+		instance.Port = pulumi.Int(internalPort).ToIntOutput()
+	} else {
+		instance.Host = pulumi.String("localhost").ToStringOutput()
+		// docker chooses the external port when it is not set
+		instance.Port = container.Ports.Index(pulumi.Int(0)).External().ApplyT(func(p *int) (int, error) {
+			if p == nil {
+				return 0, fmt.Errorf("container has no published port")
+			}
+			return *p, nil
+		}).(pulumi.IntOutput)
+	}
+	instance.Uri = pulumi.Sprintf("postgresql://%s:%s@%s:%d/%s", instance.Username, instance.Password, instance.Host, instance.Port, instance.Name)
+
+	if err := ctx.RegisterResourceOutputs(instance, pulumi.Map{
+		"host":     instance.Host,
+		"port":     instance.Port,
+		"name":     instance.Name,
+		"username": instance.Username,
+		"password": instance.Password,
+		"uri":      instance.Uri,
+	}); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
@@ -2,11 +2,17 @@ package main
 
 import (
 	"cmp"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"reflect"
 	"slices"
+	"strings"
 
 	"github.com/astromechza/score-pulumi/internal"
 
@@ -22,23 +28,40 @@ var cmpDesc = map[int]string{
 	1:  "more than",
 }
 
-func requireNArgs(n int, c int) bool {
+func requireNArgs(fs *flag.FlagSet, n int, c int) bool {
 	n -= c
-	if cmp.Compare(flag.NArg(), n) != c {
-		_, _ = os.Stderr.WriteString(fmt.Sprintf("expected %s %d arguments but got %d\n", cmpDesc[c], n, flag.NArg()))
+	if cmp.Compare(fs.NArg(), n) != c {
+		_, _ = os.Stderr.WriteString(fmt.Sprintf("expected %s %d arguments but got %d\n", cmpDesc[c], n, fs.NArg()))
 		os.Exit(2)
 		return false
 	}
 	return true
 }
 
+// stringSliceFlag is a flag.Value that collects each occurrence of a repeated flag.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func init() {
 	flag.Usage = func() {
 		_, _ = fmt.Fprintf(os.Stderr, `Usage: scorpion [subcommand] [options]
 
 Basic commands:
-  init				initialise a new scorpion project directory
-  generate			add or update a Score workload in the project and regenerate the output code
+  init				initialise a new scorpion project directory with a workload profile, use 'init -h' for options
+  generate			add or update a Score workload in the project and regenerate the output code, use 'generate -h' for options
+  list				list the workloads and resources in the project and the components they match
+  remove			remove a Score workload from the project
+  components			add, list, or remove entries in the resource component library, use 'components -h' for details
+  graph				render the component graph of the project as dot, mermaid, or json, use 'graph -h' for options
+  match				show which resource component library entries match a resource: match <type> [class] [id]
 `)
 		flag.PrintDefaults()
 	}
@@ -55,12 +78,61 @@ func main() {
 		}
 	}
 
-	if requireNArgs(1, 1) {
+	if requireNArgs(flag.CommandLine, 1, 1) {
 		var err error
-		if subcommand := flag.Arg(0); subcommand == "init" && requireNArgs(2, 0) {
-			err = scoreInit(flag.Arg(1))
-		} else if subcommand == "generate" && requireNArgs(2, -1) {
-			err = scoreGenerate(flag.Arg(1))
+		if subcommand := flag.Arg(0); subcommand == "init" {
+			fs := flag.NewFlagSet("init", flag.ExitOnError)
+			nameFlag := fs.String("name", "", "the Pulumi project name and Go module path, defaults to the name in an existing Pulumi.yaml or the name of the current directory")
+			var runtimeOptionFlags stringSliceFlag
+			fs.Var(&runtimeOptionFlags, "runtime-option", "a key=value option for the Pulumi go runtime, may be repeated")
+			forceFlag := fs.Bool("force", false, "overwrite existing project files")
+			_ = fs.Parse(flag.Args()[1:])
+			if requireNArgs(fs, 1, 0) {
+				err = scoreInit(fs.Arg(0), *nameFlag, runtimeOptionFlags, *forceFlag)
+			}
+		} else if subcommand == "generate" {
+			fs := flag.NewFlagSet("generate", flag.ExitOnError)
+			outputFlag := fs.String("output", "", "write the generated program to this file, or '-' for stdout, defaults to main.go for go or Pulumi.yaml for yaml")
+			formatFlag := fs.String("format", "", "the output format: go or yaml, defaults to the output_format in the config or go")
+			introspectFlag := fs.Bool("introspect", true, "load the component packages from the project go.mod to generate values matching the args struct field types")
+			overridesFileFlag := fs.String("overrides-file", "", "a yaml file of overrides to merge into the Score file")
+			var overridePropertyFlags stringSliceFlag
+			fs.Var(&overridePropertyFlags, "override-property", "a path=value override to set, or -path to delete, in the Score file, may be repeated")
+			var imageFlags stringSliceFlag
+			fs.Var(&imageFlags, "image", "an image ref, or container=ref, to replace the '.' placeholder images in the Score file, may be repeated")
+			allowPlaceholderFlag := fs.Bool("allow-placeholder-image", false, "allow '.' placeholder images to remain when no --image is given for them")
+			stackFlag := fs.String("stack", "", "generate the program for this stack from the stacks section of the config, otherwise the program selects the stack at runtime")
+			detectRenamesFlag := fs.Bool("detect-renames", true, "alias new components to removed components of the last generate that have the same type and params")
+			_ = fs.Parse(flag.Args()[1:])
+			if requireNArgs(fs, 1, -1) {
+				err = scoreGenerate(fs.Arg(0), generateOptions{
+					OutputFile:            *outputFlag,
+					Format:                *formatFlag,
+					Introspect:            *introspectFlag,
+					OverridesFile:         *overridesFileFlag,
+					OverrideProperties:    overridePropertyFlags,
+					Images:                imageFlags,
+					AllowPlaceholderImage: *allowPlaceholderFlag,
+					Stack:                 *stackFlag,
+					DetectRenames:         *detectRenamesFlag,
+				})
+			}
+		} else if subcommand == "list" && requireNArgs(flag.CommandLine, 1, 0) {
+			err = scoreList()
+		} else if subcommand == "remove" && requireNArgs(flag.CommandLine, 2, 0) {
+			err = scoreRemove(flag.Arg(1))
+		} else if subcommand == "match" && requireNArgs(flag.CommandLine, 2, 1) && requireNArgs(flag.CommandLine, 4, -1) {
+			err = scoreMatch(flag.Arg(1), cmp.Or(flag.Arg(2), "default"), flag.Arg(3))
+		} else if subcommand == "graph" {
+			fs := flag.NewFlagSet("graph", flag.ExitOnError)
+			formatFlag := fs.String("format", "dot", "the output format: one of dot, mermaid, or json")
+			stackFlag := fs.String("stack", "", "render the graph of this stack from the stacks section of the config")
+			_ = fs.Parse(flag.Args()[1:])
+			if requireNArgs(fs, 0, 0) {
+				err = scoreGraph(*formatFlag, *stackFlag)
+			}
+		} else if subcommand == "components" {
+			err = scoreComponents(flag.Args()[1:])
 		} else {
 			err = fmt.Errorf("unknown subcommand: '%s'", subcommand)
 		}
@@ -71,39 +143,90 @@ func main() {
 	}
 }
 
-func scoreInit(profile string) error {
-	if cfg, ok, err := internal.LoadConfig(); err != nil {
+func scoreInit(profile string, projectName string, runtimeOptions []string, force bool) error {
+	component, err := internal.BuildWorkloadComponentForProfile(profile)
+	if err != nil {
 		return err
-	} else if !ok {
-		profile, err := internal.BuildWorkloadComponentForProfile(profile)
-		if err != nil {
+	}
+	opts := internal.ProjectOptions{Name: projectName, RuntimeOptions: make(map[string]string, len(runtimeOptions))}
+	if opts.Name == "" {
+		if opts.Name, err = internal.DetectProjectName(); err != nil {
 			return err
 		}
-		if err := internal.SaveConfig(internal.ScoreConfig{
+	}
+	for _, raw := range runtimeOptions {
+		if k, v, ok := strings.Cut(raw, "="); !ok || k == "" {
+			return fmt.Errorf("invalid runtime option '%s': expected key=value", raw)
+		} else {
+			opts.RuntimeOptions[k] = v
+		}
+	}
+
+	files := make(map[string]func(io.Writer) error)
+	cfg, ok, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	} else if !ok {
+		cfg = internal.ScoreConfig{
 			Workloads:                make([]types.Workload, 0),
-			DefaultWorkloadComponent: profile,
-		}); err != nil {
-			return err
+			DefaultWorkloadComponent: component,
 		}
-	} else {
-		profile, err := internal.BuildWorkloadComponentForProfile(profile)
-		if err != nil {
+		files[internal.ConfigFile] = internal.EncodeConfig(cfg)
+	} else if !reflect.DeepEqual(component, cfg.DefaultWorkloadComponent) {
+		cfg.DefaultWorkloadComponent = component
+		files[internal.ConfigFile] = internal.EncodeConfig(cfg)
+	}
+
+	projectFiles, err := internal.BuildProjectFiles(cfg, opts)
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(projectFiles)) {
+		if _, err := os.Stat(name); err == nil && !force {
+			_, _ = fmt.Fprintf(os.Stderr, "skipping existing file %s, use --force to overwrite it\n", name)
+			continue
+		} else if err != nil && !os.IsNotExist(err) {
 			return err
 		}
-		if !reflect.DeepEqual(profile, cfg.DefaultWorkloadComponent) {
-			cfg.DefaultWorkloadComponent = profile
-			if err := internal.SaveConfig(cfg); err != nil {
-				return err
-			}
-		}
+		files[name] = projectFiles[name]
+	}
+	if err := internal.WriteFilesAtomically(files); err != nil {
+		return err
+	} else if _, ok := files[internal.GoModFile]; !ok {
+		return nil
+	}
+	// component packages are not module paths so the go tool resolves them to their modules and latest versions
+	cmd := exec.Command("go", internal.GoGetArgs(internal.ComponentPackages(cfg))...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to add the component modules to %s: %w (run 'go %s' to retry)", internal.GoModFile, err, strings.Join(cmd.Args[1:], " "))
 	}
 	return nil
 }
 
-func scoreGenerate(fileName string) error {
+// generateOptions are the flags of the generate subcommand.
+type generateOptions struct {
+	OutputFile            string
+	Format                string
+	Introspect            bool
+	OverridesFile         string
+	OverrideProperties    []string
+	Images                []string
+	AllowPlaceholderImage bool
+	Stack                 string
+	DetectRenames         bool
+}
+
+func scoreGenerate(fileName string, opts generateOptions) error {
 	var cfg internal.ScoreConfig
 	var err error
 	if fileName == "" {
+		if opts.OverridesFile != "" || len(opts.OverrideProperties) > 0 {
+			return fmt.Errorf("overrides can only be applied when generating from a Score file")
+		} else if len(opts.Images) > 0 {
+			return fmt.Errorf("images can only be applied when generating from a Score file")
+		}
 		if cfg, _, err = internal.LoadConfig(); err != nil {
 			return err
 		}
@@ -117,13 +240,28 @@ func scoreGenerate(fileName string) error {
 		if err := yaml.NewDecoder(f).Decode(&srcMap); err != nil {
 			return err
 		}
+		var overrides map[string]interface{}
+		if opts.OverridesFile != "" {
+			if overrides, err = internal.LoadOverridesFile(opts.OverridesFile); err != nil {
+				return err
+			}
+		}
+		if srcMap, err = internal.ApplyOverrides(srcMap, overrides, opts.OverrideProperties); err != nil {
+			return err
+		}
 		if err := schema.Validate(srcMap); err != nil {
 			return err
 		}
 		var spec types.Workload
 		if err := loader.MapSpec(&spec, srcMap); err != nil {
 			return err
-		} else if err := loader.Normalize(&spec, "."); err != nil {
+		} else if err := loader.Normalize(&spec, filepath.Dir(fileName)); err != nil {
+			return err
+		} else if err := internal.ResolvePlaceholderImages(&spec, opts.Images, opts.AllowPlaceholderImage); err != nil {
+			var unresolved *internal.UnresolvedPlaceholderImagesError
+			if errors.As(err, &unresolved) {
+				return fmt.Errorf("%w (use --image to set them or --allow-placeholder-image)", err)
+			}
 			return err
 		}
 
@@ -139,31 +277,228 @@ func scoreGenerate(fileName string) error {
 		}
 	}
 
-	if err := internal.ValidateComponentEntry(cfg.DefaultWorkloadComponent); err != nil {
-		return fmt.Errorf("config contains an invalid default workload component spec: %v", err)
-	} else {
-		for i, entry := range cfg.ResourceComponents {
-			if err := internal.ValidateComponentEntry(entry.ComponentEntry); err != nil {
-				return fmt.Errorf("config contains an invalid resource component spec (%d): %v", i, err)
+	var c internal.ComponentGraph
+	var stacks map[string]internal.ComponentGraph
+	if opts.Stack != "" {
+		if c, err = cfg.GenerateStackComponentGraph(opts.Stack); err != nil {
+			return err
+		}
+	} else if c, err = cfg.GenerateComponentGraph(); err != nil {
+		return err
+	} else if stacks, err = cfg.GenerateStackComponentGraphs(); err != nil {
+		return err
+	}
+	// renames are detected once so that every stack adopts the same previous names
+	aliases, renames := c.DetectRenames(cfg.PreviousComponents(opts.Stack), opts.DetectRenames)
+	for _, r := range renames {
+		_, _ = fmt.Fprintf(os.Stderr, "component '%s' matches the removed component '%s', adding an alias to adopt its resources (use --detect-renames=false to disable)\n", r.Name, r.PreviousName)
+	}
+	for _, g := range append(slices.Collect(maps.Values(stacks)), c) {
+		g.AddAliases(aliases)
+		g.AddUnparentedAliases(cfg.PreviousComponents(opts.Stack))
+	}
+	cfg.RecordComponents(opts.Stack, c)
+
+	format := cmp.Or(opts.Format, cfg.OutputFormat, internal.OutputFormatGo)
+	if format == internal.OutputFormatGo && opts.Introspect {
+		for _, g := range append(slices.Collect(maps.Values(stacks)), c) {
+			warnings, err := g.LoadArgsTypes(".")
+			if err != nil {
+				return err
+			}
+			for _, w := range warnings {
+				_, _ = fmt.Fprintf(os.Stderr, "warning: %v, generating untyped values for its components (run 'go mod tidy' to resolve it)\n", w)
 			}
 		}
 	}
+	outputFile := cmp.Or(opts.OutputFile, internal.DefaultOutputFiles[format])
+	projectName, err := internal.DetectProjectName()
+	if err != nil {
+		return err
+	}
+	render, err := internal.BuildProgram(c, stacks, format, projectName)
+	if err != nil {
+		return err
+	}
+	// the yaml program is the project file itself so it keeps the keys that the generator does not own
+	if format == internal.OutputFormatYaml && outputFile != "-" {
+		if existing, err := os.ReadFile(outputFile); err == nil {
+			render = internal.MergePulumiYaml(existing, render)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if outputFile == "-" {
+		if err := render(os.Stdout); err != nil {
+			return err
+		}
+		return internal.SaveConfig(cfg)
+	}
+	return internal.WriteFilesAtomically(map[string]func(io.Writer) error{
+		outputFile:          render,
+		internal.ConfigFile: internal.EncodeConfig(cfg),
+	})
+}
 
-	c, err := cfg.GenerateComponentGraph()
+func scoreList() error {
+	cfg, ok, err := internal.LoadConfig()
 	if err != nil {
 		return err
+	} else if !ok {
+		return fmt.Errorf("no %s found, run 'init' first", internal.ConfigFile)
 	}
+	return internal.WriteWorkloadListing(os.Stdout, cfg.ListWorkloads())
+}
 
-	f, err := internal.BuildJenFile(c)
+func scoreRemove(workloadName string) error {
+	cfg, ok, err := internal.LoadConfig()
 	if err != nil {
 		return err
+	} else if !ok {
+		return fmt.Errorf("no %s found, run 'init' first", internal.ConfigFile)
 	}
-	if err := f.Render(os.Stdout); err != nil {
+	removed, err := cfg.RemoveWorkload(workloadName)
+	if err != nil {
 		return err
 	}
-
 	if err := internal.SaveConfig(cfg); err != nil {
 		return err
 	}
+	for _, resId := range removed.OrphanedSharedResources {
+		_, _ = fmt.Fprintf(os.Stderr, "shared resource '%s' is no longer referenced by any workload\n", resId)
+	}
+	for _, resId := range slices.Sorted(maps.Keys(removed.MovedSharedParams)) {
+		if owner := removed.MovedSharedParams[resId]; owner != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: params for shared resource '%s' were defined by workload '%s' and are now defined by workload '%s'\n", resId, workloadName, owner)
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: params for shared resource '%s' were defined by workload '%s' and are no longer defined by any workload\n", resId, workloadName)
+		}
+	}
 	return nil
 }
+
+func scoreComponents(args []string) error {
+	fs := flag.NewFlagSet("components", flag.ExitOnError)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, `Usage: scorpion components [subcommand] [options]
+
+Subcommands:
+  add <pkg.Func(Args)>		add an entry to the resource component library, use 'components add -h' for options
+  list				list the entries in the resource component library in matching order
+  remove <index|type>		remove the entry at the index or all entries with the resource type
+`)
+	}
+	_ = fs.Parse(args)
+	if !requireNArgs(fs, 1, 1) {
+		return nil
+	}
+
+	cfg, ok, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no %s found, run 'init' first", internal.ConfigFile)
+	}
+
+	switch subcommand := fs.Arg(0); subcommand {
+	case "add":
+		addFs := flag.NewFlagSet("components add", flag.ExitOnError)
+		typeFlag := addFs.String("type", "", "the Score resource type provisioned by this component (required)")
+		classRegexFlag := addFs.String("class-regex", ".*", "a regex that the resource class must match")
+		idRegexFlag := addFs.String("id-regex", ".*", "a regex that the resource id (shared.<id> or workload.<name>.<alias>) must match")
+		var paramFlags stringSliceFlag
+		addFs.Var(&paramFlags, "param", "a key=value fixed param passed to the component, the value is decoded as yaml, may be repeated")
+		yamlTypeFlag := addFs.String("yaml-type", "", "the Pulumi type token of the component, required by the yaml output format")
+		var secretParamFlags stringSliceFlag
+		addFs.Var(&secretParamFlags, "secret-param", "a key=value fixed param like --param that is passed to the component as a secret, may be repeated")
+		var exportFlags stringSliceFlag
+		addFs.Var(&exportFlags, "export", "an output field of the component to export as a stack output, may be repeated")
+		var secretOutputFlags stringSliceFlag
+		addFs.Var(&secretOutputFlags, "secret-output", "an output field of the component that holds a sensitive value and is referenced as a secret, may be repeated")
+		volumeOutputFlag := addFs.String("volume-output", "", "the output field of the component that is mounted when a workload uses the resource as a container volume source")
+		positionFlag := addFs.Int("position", -1, "insert the entry at this index in the library rather than appending it")
+		_ = addFs.Parse(fs.Args()[1:])
+		if !requireNArgs(addFs, 1, 0) {
+			return nil
+		}
+		component, err := internal.ParseComponentOneLiner(addFs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if component.FixedParams, err = internal.ParseFixedParams(append(paramFlags, secretParamFlags...)); err != nil {
+			return err
+		}
+		for _, raw := range secretParamFlags {
+			k, _, _ := strings.Cut(raw, "=")
+			component.SecretFixedParams = append(component.SecretFixedParams, k)
+		}
+		component.Exports = exportFlags
+		component.SecretOutputs = secretOutputFlags
+		component.YamlType = *yamlTypeFlag
+		if err := cfg.AddResourceComponent(internal.ResourceComponentEntry{
+			ComponentEntry:     component,
+			ResourceType:       *typeFlag,
+			ResourceClassRegex: *classRegexFlag,
+			ResourceIdRegex:    *idRegexFlag,
+			VolumeOutput:       *volumeOutputFlag,
+		}, *positionFlag); err != nil {
+			return err
+		}
+		return internal.SaveConfig(cfg)
+	case "list":
+		if !requireNArgs(fs, 1, 0) {
+			return nil
+		}
+		return internal.WriteResourceComponentListing(os.Stdout, cfg.ResourceComponents)
+	case "remove":
+		if !requireNArgs(fs, 2, 0) {
+			return nil
+		}
+		removed, err := cfg.RemoveResourceComponents(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		if err := internal.SaveConfig(cfg); err != nil {
+			return err
+		}
+		for _, entry := range removed {
+			_, _ = fmt.Fprintf(os.Stderr, "removed %s for resource type '%s'\n", entry.ComponentEntry, entry.ResourceType)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown components subcommand: '%s'", subcommand)
+	}
+}
+
+func scoreMatch(resourceType, resourceClass, resourceId string) error {
+	cfg, ok, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no %s found, run 'init' first", internal.ConfigFile)
+	}
+	return internal.WriteResourceComponentMatches(os.Stdout, internal.MatchResourceComponents(cfg.ResourceComponents, resourceType, resourceClass, resourceId))
+}
+
+func scoreGraph(format string, stack string) error {
+	writer, ok := internal.GraphWriters[format]
+	if !ok {
+		return fmt.Errorf("unknown graph format '%s', expected one of %s", format, strings.Join(slices.Sorted(maps.Keys(internal.GraphWriters)), ", "))
+	}
+	cfg, ok, err := internal.LoadConfig()
+	if err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("no %s found, run 'init' first", internal.ConfigFile)
+	}
+	var g internal.ComponentGraph
+	if stack != "" {
+		g, err = cfg.GenerateStackComponentGraph(stack)
+	} else {
+		g, err = cfg.GenerateComponentGraph()
+	}
+	if err != nil {
+		return err
+	}
+	return writer(os.Stdout, g)
+}